@@ -2,35 +2,28 @@ package auth
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 
+	"github.com/navaneethkn/cronocam/internal/logging"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 )
 
-const (
-	// Scopes for Google Photos API
-	// See: https://developers.google.com/photos/library/guides/authorization
-	// We need:
-	// - photoslibrary.appendonly: for uploading photos
-	// - photoslibrary.readonly: for reading albums and photos
-	// - photoslibrary: for full access including creating albums
-	// - photoslibrary.sharing: for sharing photos
-	photosScope        = "https://www.googleapis.com/auth/photoslibrary"
-	photosAppendScope  = "https://www.googleapis.com/auth/photoslibrary.appendonly"
-	photosReadScope    = "https://www.googleapis.com/auth/photoslibrary.readonly"
-	photosSharingScope = "https://www.googleapis.com/auth/photoslibrary.sharing"
-)
-
 type Authenticator struct {
 	config    *oauth2.Config
 	tokenPath string
+	// key encrypts the stored token; nil stores it in plain text
+	key *TokenKey
+	// scopes are requested when authorizing and required of stored tokens
+	scopes []Scope
+	logger *slog.Logger
 }
 
 // openBrowser opens the specified URL in the default browser
@@ -52,7 +45,15 @@ func openBrowser(url string) error {
 	return exec.Command(cmd, args...).Start()
 }
 
-func New(credentialsPath string) (*Authenticator, error) {
+// New creates an authenticator for the OAuth client in credentialsPath that
+// keeps its token in tokenPath, encrypted with key unless it is nil. The
+// token has to grant the scopes of features, see ScopesFor.
+func New(credentialsPath, tokenPath string, key *TokenKey, features []string) (*Authenticator, error) {
+	scopes, err := ScopesFor(features)
+	if err != nil {
+		return nil, err
+	}
+
 	// Check if file exists
 	if _, err := os.Stat(credentialsPath); os.IsNotExist(err) {
 		return nil, fmt.Errorf("credentials file does not exist at %s - please create it first", credentialsPath)
@@ -63,7 +64,11 @@ func New(credentialsPath string) (*Authenticator, error) {
 		return nil, fmt.Errorf("unable to read client secret file: %v", err)
 	}
 
-	config, err := google.ConfigFromJSON(b, photosScope, photosAppendScope, photosReadScope, photosSharingScope)
+	var urls []string
+	for _, scope := range scopes {
+		urls = append(urls, scope.URL)
+	}
+	config, err := google.ConfigFromJSON(b, urls...)
 	if err != nil {
 		return nil, fmt.Errorf("unable to parse client secret file to config: %v", err)
 	}
@@ -71,38 +76,121 @@ func New(credentialsPath string) (*Authenticator, error) {
 	// Set the redirect URL to our local server
 	config.RedirectURL = "http://localhost:8080/callback"
 
-	// Set token path in same directory as credentials
-	tokenPath := filepath.Join(filepath.Dir(credentialsPath), "token.json")
+	return &Authenticator{config: config, tokenPath: tokenPath, key: key, scopes: scopes, logger: logging.Component("auth")}, nil
+}
+
+// Scopes returns the scopes the authenticator requests
+func (a *Authenticator) Scopes() []Scope {
+	return a.scopes
+}
 
-	return &Authenticator{config: config, tokenPath: tokenPath}, nil
+// MissingScopes returns the requested scopes the stored token does not
+// grant
+func (a *Authenticator) MissingScopes() ([]Scope, error) {
+	tok, _, err := a.readToken()
+	if err != nil {
+		return nil, err
+	}
+	return missingScopes(tok.grantedScopes(), a.scopes), nil
 }
 
+// GetClient returns a client using the stored token, authorizing in the
+// browser when there is none. A token that lacks a requested scope is an
+// error, since runs may be unattended; Authorize replaces it.
 func (a *Authenticator) GetClient(ctx context.Context) (*http.Client, error) {
-	tok, err := a.GetTokenFromFile()
+	return a.client(ctx, false)
+}
+
+// Authorize is GetClient for interactive use: a token that lacks a
+// requested scope is replaced by authorizing in the browser again
+func (a *Authenticator) Authorize(ctx context.Context) (*http.Client, error) {
+	return a.client(ctx, true)
+}
+
+func (a *Authenticator) client(ctx context.Context, reauthorize bool) (*http.Client, error) {
+	tok, encrypted, err := a.readToken()
+	if err != nil && encrypted {
+		// Authorizing again would replace a token that is only unreadable
+		// because of the key
+		return nil, fmt.Errorf("failed to read token %s: %v", a.tokenPath, err)
+	}
+	if err == nil {
+		if missing := missingScopes(tok.grantedScopes(), a.scopes); len(missing) > 0 {
+			err = fmt.Errorf("token lacks %s", strings.Join(scopeNames(missing), ", "))
+			if !reauthorize {
+				return nil, fmt.Errorf("%v, run setup to authorize it", err)
+			}
+		}
+	}
 	if err != nil {
+		a.logger.Info("No usable token, requesting authorization", "path", a.tokenPath, "err", err)
 		tok, err = a.getTokenFromWeb(ctx)
 		if err != nil {
 			return nil, err
 		}
+		// Scopes can be unchecked on the consent screen
+		if missing := missingScopes(tok.grantedScopes(), a.scopes); len(missing) > 0 {
+			return nil, fmt.Errorf("access was not granted to %s", strings.Join(scopeNames(missing), ", "))
+		}
 		if err := a.saveToken(tok); err != nil {
 			return nil, err
 		}
+		a.logger.Info("Saved token", "path", a.tokenPath)
+	} else {
+		a.logger.Debug("Loaded token", "path", a.tokenPath, "expiry", tok.Expiry)
 	}
-	return a.config.Client(ctx, tok), nil
+	return a.config.Client(ctx, tok.Token), nil
 }
 
+// GetTokenFromFile reads the stored token, decrypting it if it is
+// encrypted. Plain text tokens are still read when a key is configured so
+// they keep working until they are migrated.
 func (a *Authenticator) GetTokenFromFile() (*oauth2.Token, error) {
-	f, err := os.Open(a.tokenPath)
+	tok, _, err := a.readToken()
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-	tok := &oauth2.Token{}
-	err = json.NewDecoder(f).Decode(tok)
-	return tok, err
+	return tok.Token, nil
+}
+
+func (a *Authenticator) readToken() (*storedToken, bool, error) {
+	data, err := os.ReadFile(a.tokenPath)
+	if err != nil {
+		return nil, false, err
+	}
+	tok, encrypted, err := decodeToken(data, a.key)
+	if err == nil && !encrypted && a.key != nil {
+		a.logger.Warn("Token is stored in plain text, run auth migrate-token to encrypt it", "path", a.tokenPath)
+	}
+	return tok, encrypted, err
+}
+
+// MigrateToken encrypts a plain text token with the configured key. It
+// reports false when the token was already encrypted.
+func (a *Authenticator) MigrateToken() (bool, error) {
+	if a.key == nil {
+		return false, fmt.Errorf("no token encryption key is configured")
+	}
+	data, err := os.ReadFile(a.tokenPath)
+	if err != nil {
+		return false, err
+	}
+	// Decrypting an encrypted token checks that the key matches
+	tok, encrypted, err := decodeToken(data, a.key)
+	if err != nil {
+		return false, err
+	}
+	if encrypted {
+		return false, nil
+	}
+	if err := a.saveToken(tok); err != nil {
+		return false, err
+	}
+	a.logger.Info("Encrypted token", "path", a.tokenPath, "key", a.key.Source())
+	return true, nil
 }
 
-func (a *Authenticator) getTokenFromWeb(ctx context.Context) (*oauth2.Token, error) {
+func (a *Authenticator) getTokenFromWeb(ctx context.Context) (*storedToken, error) {
 	// Create a channel to receive the auth code
 	codeChan := make(chan string)
 
@@ -133,18 +221,23 @@ func (a *Authenticator) getTokenFromWeb(ctx context.Context) (*oauth2.Token, err
 	// Start the server in a goroutine
 	go func() {
 		if err := server.ListenAndServe(); err != http.ErrServerClosed {
-			fmt.Printf("Error starting server: %v\n", err)
+			a.logger.Error("Failed to start callback server", "err", err)
 			codeChan <- ""
 		}
 	}()
 
 	// Generate the auth URL with the correct redirect URI
-	authURL := a.config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
+	// Let the user pick the account, which matters when one browser is
+	// signed in to the accounts of several profiles, and ask for consent so
+	// a refresh token is issued again when an account is authorized anew
+	authURL := a.config.AuthCodeURL("state-token", oauth2.AccessTypeOffline,
+		oauth2.SetAuthURLParam("prompt", "select_account consent"))
 	fmt.Printf("Opening the following link in your browser: \n%v\n", authURL)
 
 	// Open the URL in the default browser
 	if err := openBrowser(authURL); err != nil {
-		fmt.Printf("Failed to open browser: %v\nPlease open the URL manually.\n", err)
+		a.logger.Warn("Failed to open browser", "err", err)
+		fmt.Println("Please open the URL manually.")
 	}
 
 	// Wait for the code
@@ -159,17 +252,20 @@ func (a *Authenticator) getTokenFromWeb(ctx context.Context) (*oauth2.Token, err
 		return nil, fmt.Errorf("unable to retrieve token from web: %v", err)
 	}
 
-	return tok, nil
+	scope, _ := tok.Extra("scope").(string)
+	return &storedToken{Token: tok, Scope: scope}, nil
 }
 
-func (a *Authenticator) saveToken(token *oauth2.Token) error {
+func (a *Authenticator) saveToken(token *storedToken) error {
 	if err := os.MkdirAll(filepath.Dir(a.tokenPath), 0755); err != nil {
 		return fmt.Errorf("unable to create token directory: %v", err)
 	}
-	f, err := os.OpenFile(a.tokenPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	data, err := encodeToken(token, a.key)
 	if err != nil {
+		return fmt.Errorf("unable to encode oauth token: %v", err)
+	}
+	if err := writeFileAtomic(a.tokenPath, data); err != nil {
 		return fmt.Errorf("unable to cache oauth token: %v", err)
 	}
-	defer f.Close()
-	return json.NewEncoder(f).Encode(token)
+	return nil
 }
@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Google endpoints used to inspect and revoke tokens
+const (
+	revokeURL    = "https://oauth2.googleapis.com/revoke"
+	tokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+	userInfoURL  = "https://openidconnect.googleapis.com/v1/userinfo"
+)
+
+// requestTimeout bounds the calls to Google's OAuth endpoints
+const requestTimeout = 30 * time.Second
+
+// refresh exchanges the refresh token for a new access token, even if the
+// current one is still valid
+func (a *Authenticator) refresh(ctx context.Context, tok *storedToken) (*storedToken, error) {
+	if tok.RefreshToken == "" {
+		return nil, fmt.Errorf("token has no refresh token, run setup to authorize again")
+	}
+	expired := *tok.Token
+	expired.AccessToken = ""
+	fresh, err := a.config.TokenSource(ctx, &expired).Token()
+	if err != nil {
+		return nil, err
+	}
+	scope, _ := fresh.Extra("scope").(string)
+	if scope == "" {
+		scope = tok.Scope
+	}
+	return &storedToken{Token: fresh, Scope: scope}, nil
+}
+
+// CheckRefresh reports whether the stored refresh token still works. The
+// new access token is discarded.
+func (a *Authenticator) CheckRefresh(ctx context.Context) error {
+	tok, _, err := a.readToken()
+	if err != nil {
+		return err
+	}
+	_, err = a.refresh(ctx, tok)
+	return err
+}
+
+// Refresh gets a new access token with the stored refresh token and saves
+// it
+func (a *Authenticator) Refresh(ctx context.Context) (*oauth2.Token, error) {
+	tok, _, err := a.readToken()
+	if err != nil {
+		return nil, err
+	}
+	fresh, err := a.refresh(ctx, tok)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.saveToken(fresh); err != nil {
+		return nil, err
+	}
+	a.logger.Info("Refreshed token", "path", a.tokenPath, "expiry", fresh.Expiry)
+	return fresh.Token, nil
+}
+
+// Revoke revokes the stored token at Google and deletes it. Revoking the
+// refresh token also invalidates its access tokens. A token Google no
+// longer knows is deleted too.
+func (a *Authenticator) Revoke(ctx context.Context) error {
+	tok, _, err := a.readToken()
+	if err != nil {
+		return err
+	}
+	token := tok.RefreshToken
+	if token == "" {
+		token = tok.AccessToken
+	}
+
+	form := url.Values{"token": {token}}.Encode()
+	err = requestJSON(ctx, http.DefaultClient, "POST", revokeURL, strings.NewReader(form), nil)
+	var statusErr *statusError
+	switch {
+	case err == nil:
+		a.logger.Info("Revoked token", "path", a.tokenPath)
+	case errors.As(err, &statusErr) && statusErr.code == http.StatusBadRequest && strings.Contains(statusErr.body, "invalid_token"):
+		a.logger.Warn("Token was already revoked or expired", "path", a.tokenPath)
+	default:
+		return fmt.Errorf("failed to revoke token: %v", err)
+	}
+
+	if err := os.Remove(a.tokenPath); err != nil {
+		return fmt.Errorf("failed to delete token: %v", err)
+	}
+	return nil
+}
+
+// TokenInfo is what Google reports about an access token
+type TokenInfo struct {
+	Scopes []string
+	Expiry time.Time
+}
+
+// TokenInfo asks Google about the stored access token, refreshing it first
+// if it expired. The refreshed token is not saved.
+func (a *Authenticator) TokenInfo(ctx context.Context) (*TokenInfo, error) {
+	tok, _, err := a.readToken()
+	if err != nil {
+		return nil, err
+	}
+	source := a.config.TokenSource(ctx, tok.Token)
+	access, err := source.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Scope     string `json:"scope"`
+		ExpiresIn string `json:"expires_in"`
+	}
+	// The token is posted so it never shows up in a URL of an error
+	form := url.Values{"access_token": {access.AccessToken}}.Encode()
+	if err := requestJSON(ctx, http.DefaultClient, "POST", tokenInfoURL, strings.NewReader(form), &result); err != nil {
+		return nil, err
+	}
+	info := &TokenInfo{Scopes: strings.Fields(result.Scope), Expiry: access.Expiry}
+	if seconds, err := time.ParseDuration(result.ExpiresIn + "s"); err == nil {
+		info.Expiry = time.Now().Add(seconds).Truncate(time.Second)
+	}
+	return info, nil
+}
+
+// StoredClient returns a client using the stored token. Unlike GetClient
+// it never starts the browser flow.
+func (a *Authenticator) StoredClient(ctx context.Context) (*http.Client, error) {
+	tok, _, err := a.readToken()
+	if err != nil {
+		return nil, err
+	}
+	return a.config.Client(ctx, tok.Token), nil
+}
+
+// AccountEmail returns the email address of the authorized account. It is
+// only available to tokens that were granted an email scope, so an empty
+// address without an error means Google did not share it.
+func (a *Authenticator) AccountEmail(ctx context.Context) (string, error) {
+	client, err := a.StoredClient(ctx)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		Email string `json:"email"`
+	}
+	err = requestJSON(ctx, client, "GET", userInfoURL, nil, &result)
+	var statusErr *statusError
+	if errors.As(err, &statusErr) && (statusErr.code == http.StatusUnauthorized || statusErr.code == http.StatusForbidden) {
+		return "", nil
+	}
+	return result.Email, err
+}
+
+// statusError is an unexpected HTTP status of an OAuth endpoint
+type statusError struct {
+	url  string
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s returned status %d: %s", e.url, e.code, strings.TrimSpace(e.body))
+}
+
+// requestJSON calls an OAuth endpoint and decodes its JSON response into v
+// unless it is nil. A body is sent as a form.
+func requestJSON(ctx context.Context, client *http.Client, method, endpoint string, body io.Reader, v interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &statusError{url: endpoint, code: resp.StatusCode, body: string(data)}
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
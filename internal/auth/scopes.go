@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Features that decide which scopes are requested
+const (
+	FeatureUpload = "upload" // upload photos and videos
+	FeatureAlbums = "albums" // create albums and add uploads to them
+)
+
+// Scopes for Google Photos API
+// See: https://developers.google.com/photos/overview/authorization
+const (
+	ScopeAppendOnly     = "https://www.googleapis.com/auth/photoslibrary.appendonly"
+	ScopeEditAppCreated = "https://www.googleapis.com/auth/photoslibrary.edit.appcreateddata"
+
+	// scopeFull was requested by earlier versions and covers every feature
+	scopeFull = "https://www.googleapis.com/auth/photoslibrary"
+)
+
+// Scope is an OAuth scope and why it is requested
+type Scope struct {
+	URL     string
+	Feature string
+	Reason  string
+}
+
+// featureScopes lists the scopes of each feature. Uploading only needs to
+// add items, so nothing in the library can be read, changed or deleted
+// unless another feature is turned on.
+var featureScopes = map[string][]Scope{
+	FeatureUpload: {{URL: ScopeAppendOnly, Feature: FeatureUpload,
+		Reason: "upload photos and videos; cannot see, change or delete anything"}},
+	FeatureAlbums: {{URL: ScopeEditAppCreated, Feature: FeatureAlbums,
+		Reason: "create albums and add uploads to them; limited to albums created by cronocam"}},
+}
+
+// ScopesFor returns the scopes needed by features. Uploading is always
+// included.
+func ScopesFor(features []string) ([]Scope, error) {
+	scopes := featureScopes[FeatureUpload]
+	for _, feature := range features {
+		extra, ok := featureScopes[feature]
+		if !ok {
+			return nil, fmt.Errorf("unknown feature %q", feature)
+		}
+		for _, scope := range extra {
+			if !slices.ContainsFunc(scopes, func(s Scope) bool { return s.URL == scope.URL }) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes, nil
+}
+
+// ShortScope strips the common prefix from a scope URL for display
+func ShortScope(url string) string {
+	return strings.TrimPrefix(url, "https://www.googleapis.com/auth/")
+}
+
+// missingScopes returns the scopes that are not among the granted ones.
+// Tokens saved before scopes were recorded were granted full access.
+func missingScopes(granted []string, scopes []Scope) []Scope {
+	if granted == nil || slices.Contains(granted, scopeFull) {
+		return nil
+	}
+	var missing []Scope
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope.URL) {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
+
+// scopeNames returns the short names of scopes
+func scopeNames(scopes []Scope) []string {
+	names := make([]string, len(scopes))
+	for i, scope := range scopes {
+		names[i] = ShortScope(scope.URL)
+	}
+	return names
+}
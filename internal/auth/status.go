@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"os"
+	"time"
+)
+
+// Token states reported by TokenStatus
+const (
+	TokenValid       = "valid"       // the access token has not expired
+	TokenRefreshable = "refreshable" // the access token expired but can be refreshed
+	TokenExpired     = "expired"     // the access token expired and cannot be refreshed
+	TokenMissing     = "missing"     // setup has not been run
+	TokenInvalid     = "invalid"     // the token file could not be read
+)
+
+// TokenStatus describes the stored token without contacting Google
+type TokenStatus struct {
+	State           string
+	Expiry          *time.Time
+	HasRefreshToken bool
+	// Encrypted is set when the token file is encrypted
+	Encrypted bool
+	// Scopes are the granted scopes, nil when they were not recorded
+	Scopes []string
+	// MissingScopes are the requested scopes the token does not grant
+	MissingScopes []string
+}
+
+// TokenStatus reads the stored token and reports whether it can be used
+func (a *Authenticator) TokenStatus() TokenStatus {
+	tok, encrypted, err := a.readToken()
+	if os.IsNotExist(err) {
+		return TokenStatus{State: TokenMissing}
+	}
+	if err != nil {
+		return TokenStatus{State: TokenInvalid, Encrypted: encrypted}
+	}
+
+	status := TokenStatus{
+		HasRefreshToken: tok.RefreshToken != "",
+		Encrypted:       encrypted,
+		Scopes:          tok.grantedScopes(),
+		MissingScopes:   scopeNames(missingScopes(tok.grantedScopes(), a.scopes)),
+	}
+	if !tok.Expiry.IsZero() {
+		expiry := tok.Expiry
+		status.Expiry = &expiry
+	}
+
+	switch {
+	case tok.Valid():
+		status.State = TokenValid
+	case status.HasRefreshToken:
+		status.State = TokenRefreshable
+	case tok.AccessToken == "":
+		status.State = TokenInvalid
+	default:
+		status.State = TokenExpired
+	}
+	return status
+}
@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/oauth2"
+)
+
+// Key sources of encrypted tokens
+const (
+	KeyPassphrase = "passphrase" // a passphrase from the environment or a file
+	KeyMachineID  = "machine-id" // the machine-id of the host
+)
+
+const (
+	// encryptedTokenVersion is the format of encrypted token files
+	encryptedTokenVersion = 1
+	// kdfIterations is the PBKDF2-HMAC-SHA256 work factor
+	kdfIterations = 600000
+	saltSize      = 16
+)
+
+// ErrTokenEncrypted is returned when an encrypted token is read without a key
+var ErrTokenEncrypted = errors.New("token is encrypted, but no token encryption key is configured")
+
+// storedToken is a saved token with the scopes it was granted, which
+// oauth2.Token does not keep
+type storedToken struct {
+	*oauth2.Token
+	// Scope is the space-separated list of granted scopes; empty for tokens
+	// saved before it was recorded
+	Scope string `json:"scope,omitempty"`
+}
+
+// grantedScopes returns the granted scopes, or nil when they are unknown
+func (t *storedToken) grantedScopes() []string {
+	if t.Scope == "" {
+		return nil
+	}
+	return strings.Fields(t.Scope)
+}
+
+// TokenKey is the secret stored tokens are encrypted with. The AES-256-GCM
+// key is derived from it with PBKDF2 and a random salt on every save.
+type TokenKey struct {
+	source string
+	secret []byte
+}
+
+// PassphraseKey creates a key from a passphrase
+func PassphraseKey(passphrase string) (*TokenKey, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("token passphrase is empty")
+	}
+	return &TokenKey{source: KeyPassphrase, secret: []byte(passphrase)}, nil
+}
+
+// MachineIDKey creates a key from the machine-id file of the host. Tokens
+// encrypted with it can only be read on the same installation, which keeps
+// them safe in backups but not from other users of the machine.
+func MachineIDKey(path string) (*TokenKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read machine id: %v", err)
+	}
+	id := strings.TrimSpace(string(data))
+	if id == "" {
+		return nil, fmt.Errorf("machine id file %s is empty", path)
+	}
+	return &TokenKey{source: KeyMachineID, secret: []byte(id)}, nil
+}
+
+// Source returns KeyPassphrase or KeyMachineID
+func (k *TokenKey) Source() string {
+	return k.source
+}
+
+// encryptedToken is the file format of an encrypted token. The JSON keeps
+// it recognizable; only the token itself is secret.
+type encryptedToken struct {
+	Version    int    `json:"version"`
+	KeySource  string `json:"key_source"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// additionalData binds the header fields to the ciphertext
+func (e *encryptedToken) additionalData() []byte {
+	return []byte(fmt.Sprintf("cronocam token v%d %s %s %d", e.Version, e.KeySource, e.KDF, e.Iterations))
+}
+
+func (k *TokenKey) gcm(salt []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2.Key(k.secret, salt, kdfIterations, 32, sha256.New))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (k *TokenKey) encrypt(tok *storedToken) ([]byte, error) {
+	plaintext, err := json.Marshal(tok)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &encryptedToken{
+		Version:    encryptedTokenVersion,
+		KeySource:  k.source,
+		KDF:        "pbkdf2-sha256",
+		Iterations: kdfIterations,
+		Salt:       make([]byte, saltSize),
+	}
+	if _, err := rand.Read(e.Salt); err != nil {
+		return nil, err
+	}
+	aead, err := k.gcm(e.Salt)
+	if err != nil {
+		return nil, err
+	}
+	e.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(e.Nonce); err != nil {
+		return nil, err
+	}
+	e.Ciphertext = aead.Seal(nil, e.Nonce, plaintext, e.additionalData())
+	return json.MarshalIndent(e, "", "  ")
+}
+
+func (k *TokenKey) decrypt(e *encryptedToken) (*storedToken, error) {
+	if e.Version != encryptedTokenVersion || e.KDF != "pbkdf2-sha256" || e.Iterations != kdfIterations {
+		return nil, fmt.Errorf("unsupported encrypted token format")
+	}
+	if e.KeySource != k.source {
+		return nil, fmt.Errorf("token is encrypted with a %s key, but a %s key is configured", e.KeySource, k.source)
+	}
+	aead, err := k.gcm(e.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(e.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid encrypted token")
+	}
+	plaintext, err := aead.Open(nil, e.Nonce, e.Ciphertext, e.additionalData())
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt token, wrong %s?", k.source)
+	}
+	tok := &storedToken{Token: &oauth2.Token{}}
+	if err := json.Unmarshal(plaintext, tok); err != nil {
+		return nil, err
+	}
+	return tok, nil
+}
+
+// decodeToken reads a token file, decrypting it when it is encrypted. It
+// reports whether the file was encrypted.
+func decodeToken(data []byte, key *TokenKey) (*storedToken, bool, error) {
+	var e encryptedToken
+	if err := json.Unmarshal(data, &e); err == nil && e.Ciphertext != nil {
+		if key == nil {
+			return nil, true, ErrTokenEncrypted
+		}
+		tok, err := key.decrypt(&e)
+		return tok, true, err
+	}
+
+	tok := &storedToken{Token: &oauth2.Token{}}
+	err := json.NewDecoder(bytes.NewReader(data)).Decode(tok)
+	return tok, false, err
+}
+
+// encodeToken renders a token file, encrypted when a key is given
+func encodeToken(tok *storedToken, key *TokenKey) ([]byte, error) {
+	if key != nil {
+		return key.encrypt(tok)
+	}
+	return json.Marshal(tok)
+}
+
+// writeFileAtomic replaces path with data, readable only by the owner. A
+// crash never leaves a truncated token behind.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func testToken() *storedToken {
+	return &storedToken{
+		Token: &oauth2.Token{
+			AccessToken:  "access",
+			TokenType:    "Bearer",
+			RefreshToken: "refresh",
+			Expiry:       time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		Scope: "photoslibrary.appendonly photoslibrary.readonly.appcreateddata",
+	}
+}
+
+func mustPassphraseKey(t *testing.T, passphrase string) *TokenKey {
+	t.Helper()
+	key, err := PassphraseKey(passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestEncryptedTokenRoundTrip(t *testing.T) {
+	key := mustPassphraseKey(t, "correct horse")
+	data, err := encodeToken(testToken(), key)
+	if err != nil {
+		t.Fatalf("encodeToken failed: %v", err)
+	}
+	if strings.Contains(string(data), "refresh") {
+		t.Errorf("encrypted token contains the refresh token: %s", data)
+	}
+
+	tok, encrypted, err := decodeToken(data, key)
+	if err != nil {
+		t.Fatalf("decodeToken failed: %v", err)
+	}
+	want := testToken()
+	if !encrypted || tok.AccessToken != want.AccessToken || tok.RefreshToken != want.RefreshToken ||
+		!tok.Expiry.Equal(want.Expiry) || tok.Scope != want.Scope {
+		t.Errorf("decodeToken = %+v, %v, want %+v, true", tok, encrypted, want)
+	}
+
+	if _, _, err := decodeToken(data, nil); !errors.Is(err, ErrTokenEncrypted) {
+		t.Errorf("decodeToken without a key = %v, want ErrTokenEncrypted", err)
+	}
+}
+
+func TestPlainTokenRoundTrip(t *testing.T) {
+	data, err := encodeToken(testToken(), nil)
+	if err != nil {
+		t.Fatalf("encodeToken failed: %v", err)
+	}
+	// A plain token is read whether or not a key is configured
+	for _, key := range []*TokenKey{nil, mustPassphraseKey(t, "unused")} {
+		tok, encrypted, err := decodeToken(data, key)
+		if err != nil || encrypted || tok.RefreshToken != "refresh" || tok.Scope != testToken().Scope {
+			t.Errorf("decodeToken = %+v, %v, %v", tok, encrypted, err)
+		}
+	}
+}
+
+func TestDecryptRejected(t *testing.T) {
+	key := mustPassphraseKey(t, "correct horse")
+	data, err := key.encrypt(testToken())
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+
+	// A machine-id key with the same secret derives the same AES key, so
+	// only the additional data tells the key sources apart
+	machineIDFile := filepath.Join(t.TempDir(), "machine-id")
+	if err := os.WriteFile(machineIDFile, []byte("correct horse\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	machineIDKey, err := MachineIDKey(machineIDFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		key    *TokenKey
+		tamper func(e *encryptedToken)
+	}{
+		{"wrong passphrase", mustPassphraseKey(t, "wrong horse"), func(e *encryptedToken) {}},
+		{"other key source", machineIDKey, func(e *encryptedToken) {}},
+		{"tampered key source", machineIDKey, func(e *encryptedToken) { e.KeySource = KeyMachineID }},
+		{"fewer iterations", key, func(e *encryptedToken) { e.Iterations = 1000 }},
+		{"more iterations", key, func(e *encryptedToken) { e.Iterations = kdfIterations * 2 }},
+		{"other KDF", key, func(e *encryptedToken) { e.KDF = "scrypt" }},
+		{"other version", key, func(e *encryptedToken) { e.Version = 2 }},
+		{"tampered salt", key, func(e *encryptedToken) { e.Salt[0] ^= 1 }},
+		{"short nonce", key, func(e *encryptedToken) { e.Nonce = e.Nonce[:4] }},
+		{"tampered ciphertext", key, func(e *encryptedToken) { e.Ciphertext[0] ^= 1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e encryptedToken
+			if err := json.Unmarshal(data, &e); err != nil {
+				t.Fatal(err)
+			}
+			tt.tamper(&e)
+			tampered, err := json.Marshal(&e)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tok, encrypted, err := decodeToken(tampered, tt.key)
+			if err == nil || !encrypted {
+				t.Errorf("decodeToken = %+v, %v, %v, want an error", tok, encrypted, err)
+			}
+		})
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(path, []byte("new")); err != nil {
+		t.Fatalf("writeFileAtomic failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "new\n" {
+		t.Errorf("file = %q, %v, want %q", data, err, "new\n")
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("file mode = %v, %v, want 0600", info.Mode().Perm(), err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d files, want only the token", len(entries))
+	}
+}
@@ -0,0 +1,315 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/navaneethkn/cronocam/internal/auth"
+	"github.com/navaneethkn/cronocam/internal/config"
+	"github.com/navaneethkn/cronocam/internal/uploader"
+	"github.com/spf13/cobra"
+)
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Manage the stored OAuth token",
+	Long: `Inspect, refresh, test and revoke the OAuth token saved by setup for the
+selected profile.
+
+Use "auth migrate-token" to encrypt a token that was saved in plain text
+before token.encryption was configured.`,
+	Args: cobra.NoArgs,
+}
+
+var authStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the stored token and what Google reports about it",
+	Long: `Show the expiry, encryption and scopes of the stored token. Unless
+--offline is given, Google is asked whether the refresh token still works,
+which scopes the token was granted and, when a scope allows it, the email
+address of the account.`,
+	Args: cobra.NoArgs,
+	RunE: runAuthStatus,
+}
+
+var authRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Get a new access token with the refresh token",
+	Args:  cobra.NoArgs,
+	RunE:  runAuthRefresh,
+}
+
+var authRevokeCmd = &cobra.Command{
+	Use:   "revoke",
+	Short: "Revoke the token at Google and delete it",
+	Long: `Revoke the stored token at Google, which removes the access of cronocam from
+the Google account, and delete the local token. Run setup to authorize
+again.`,
+	Args: cobra.NoArgs,
+	RunE: runAuthRevoke,
+}
+
+var authTestCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Check the token with a cheap Google Photos API call",
+	Long: `Check the stored token with a cheap Google Photos API call that lists one
+album created by cronocam. A token that is accepted but whose scopes do not
+allow reading albums still passes, since uploads do not need to.`,
+	Args: cobra.NoArgs,
+	RunE: runAuthTest,
+}
+
+var authMigrateTokenCmd = &cobra.Command{
+	Use:   "migrate-token",
+	Short: "Encrypt a plain text token",
+	Long: `Encrypt the stored token of the selected profile in place with the key set
+up by token.encryption. The passphrase is read from PHOTOS_TOKEN_PASSPHRASE
+or token.passphrase_file; the machine-id key from token.machine_id_file or
+/etc/machine-id.
+
+Tokens that are already encrypted are left alone, so the command can be
+run for every profile.`,
+	Args: cobra.NoArgs,
+	RunE: runAuthMigrateToken,
+}
+
+func init() {
+	rootCmd.AddCommand(authCmd)
+	authCmd.AddCommand(authStatusCmd)
+	authCmd.AddCommand(authRefreshCmd)
+	authCmd.AddCommand(authRevokeCmd)
+	authCmd.AddCommand(authTestCmd)
+	authCmd.AddCommand(authMigrateTokenCmd)
+
+	authStatusCmd.Flags().Bool("offline", false, "only show the stored token, without contacting Google")
+	addOutputFlag(authStatusCmd)
+}
+
+// storedTokenError explains why the stored token cannot be used
+func storedTokenError(err error) error {
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no token at %s, run setup first", config.GetTokenPath())
+	}
+	return fmt.Errorf("failed to read token %s: %v", config.GetTokenPath(), err)
+}
+
+func runAuthStatus(cmd *cobra.Command, args []string) error {
+	offline, _ := cmd.Flags().GetBool("offline")
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	authenticator, err := newAuthenticator()
+	if err != nil {
+		return fmt.Errorf("failed to create authenticator: %v", err)
+	}
+	status := authenticator.TokenStatus()
+	out := authStatusOutput{
+		Profile:   config.GetProfile(),
+		TokenPath: config.GetTokenPath(),
+		Token:     newAuthOutput(status),
+	}
+	if !offline && status.State != auth.TokenMissing && status.State != auth.TokenInvalid {
+		out.Google = checkTokenAtGoogle(authenticator, status)
+	}
+
+	if format != outputTable {
+		return writeOutput(format, out)
+	}
+
+	if out.Profile != "" {
+		fmt.Printf("Profile: %s\n", out.Profile)
+	}
+	fmt.Printf("Token: %s\n", out.TokenPath)
+	state := status.State
+	if status.Encrypted {
+		state += ", encrypted"
+	}
+	fmt.Printf("State: %s\n", state)
+	if status.State == auth.TokenMissing || status.State == auth.TokenInvalid {
+		return nil
+	}
+	if status.Expiry != nil {
+		fmt.Printf("Access token expires: %s (%s)\n", status.Expiry.Local().Format(time.RFC3339), humanize.Time(*status.Expiry))
+	}
+	fmt.Printf("Refresh token: %s\n", yesNo(status.HasRefreshToken))
+	if status.Scopes != nil {
+		fmt.Printf("Scopes: %s\n", shortScopes(status.Scopes))
+	} else {
+		fmt.Printf("Scopes: not recorded\n")
+	}
+	if len(status.MissingScopes) > 0 {
+		fmt.Printf("Missing scopes: %s (run setup to authorize them)\n", strings.Join(status.MissingScopes, ", "))
+	}
+
+	if out.Google == nil {
+		return nil
+	}
+	switch out.Google.Refresh {
+	case "ok":
+		fmt.Printf("Refresh: ok\n")
+	case "failed":
+		fmt.Printf("Refresh: failed: %s\n", out.Google.RefreshError)
+	default:
+		fmt.Printf("Refresh: not possible without a refresh token\n")
+	}
+	if out.Google.Error != "" {
+		fmt.Printf("Google: %s\n", out.Google.Error)
+		return nil
+	}
+	fmt.Printf("Granted scopes: %s\n", shortScopes(out.Google.Scopes))
+	if out.Google.Email != "" {
+		fmt.Printf("Account: %s\n", out.Google.Email)
+	} else {
+		fmt.Printf("Account: not available, the token has no email scope\n")
+	}
+	return nil
+}
+
+// checkTokenAtGoogle asks Google whether the token can be refreshed, which
+// scopes it has and whose it is
+func checkTokenAtGoogle(authenticator *auth.Authenticator, status auth.TokenStatus) *authGoogleOutput {
+	ctx := context.Background()
+	out := &authGoogleOutput{Refresh: "no_refresh_token"}
+	if status.HasRefreshToken {
+		out.Refresh = "ok"
+		if err := authenticator.CheckRefresh(ctx); err != nil {
+			out.Refresh = "failed"
+			out.RefreshError = err.Error()
+		}
+	}
+
+	info, err := authenticator.TokenInfo(ctx)
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	out.Scopes = info.Scopes
+
+	email, err := authenticator.AccountEmail(ctx)
+	if err != nil {
+		slog.Debug("Failed to get account email", "err", err)
+	}
+	out.Email = email
+	return out
+}
+
+func shortScopes(scopes []string) string {
+	short := make([]string, len(scopes))
+	for i, scope := range scopes {
+		short[i] = auth.ShortScope(scope)
+	}
+	return strings.Join(short, ", ")
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func runAuthRefresh(cmd *cobra.Command, args []string) error {
+	authenticator, err := newAuthenticator()
+	if err != nil {
+		return fmt.Errorf("failed to create authenticator: %v", err)
+	}
+	if _, err := authenticator.GetTokenFromFile(); err != nil {
+		return storedTokenError(err)
+	}
+	tok, err := authenticator.Refresh(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to refresh token: %v", err)
+	}
+	fmt.Printf("Refreshed token, the access token expires %s\n", humanize.Time(tok.Expiry))
+	return nil
+}
+
+func runAuthRevoke(cmd *cobra.Command, args []string) error {
+	authenticator, err := newAuthenticator()
+	if err != nil {
+		return fmt.Errorf("failed to create authenticator: %v", err)
+	}
+	if _, err := authenticator.GetTokenFromFile(); err != nil {
+		return storedTokenError(err)
+	}
+	if err := authenticator.Revoke(context.Background()); err != nil {
+		return err
+	}
+	fmt.Printf("Revoked and deleted token %s, run setup to authorize again\n", config.GetTokenPath())
+	return nil
+}
+
+func runAuthTest(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	authenticator, err := newAuthenticator()
+	if err != nil {
+		return fmt.Errorf("failed to create authenticator: %v", err)
+	}
+	client, err := authenticator.StoredClient(ctx)
+	if err != nil {
+		return storedTokenError(err)
+	}
+	photoUploader, err := uploader.New(client, uploader.Config{
+		ChunkSize:         config.GetChunkSize(),
+		MaxRetries:        config.GetMaxRetries(),
+		RequestsPerSecond: config.GetRequestsPerSecond(),
+		MaxBurst:          config.GetMaxBurst(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create uploader: %v", err)
+	}
+
+	start := time.Now()
+	err = photoUploader.CheckAccess(ctx)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	var apiErr *uploader.APIError
+	switch {
+	case err == nil:
+		fmt.Printf("OK: the Google Photos API accepted the token (%s)\n", elapsed)
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusForbidden && apiErr.Reason() == uploader.ReasonScopeInsufficient:
+		fmt.Printf("OK: the Google Photos API accepted the token; its scopes do not allow reading albums (%s)\n", elapsed)
+	default:
+		return fmt.Errorf("authenticated API call failed: %v", err)
+	}
+	return nil
+}
+
+func runAuthMigrateToken(cmd *cobra.Command, args []string) error {
+	mode, err := config.GetTokenEncryption()
+	if err != nil {
+		return err
+	}
+	if mode == config.TokenEncryptionNone {
+		return fmt.Errorf("token.encryption is %s, set it to %s or %s first",
+			config.TokenEncryptionNone, config.TokenEncryptionPassphrase, config.TokenEncryptionMachineID)
+	}
+
+	authenticator, err := newAuthenticator()
+	if err != nil {
+		return fmt.Errorf("failed to create authenticator: %v", err)
+	}
+	tokenPath := config.GetTokenPath()
+	migrated, err := authenticator.MigrateToken()
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no token at %s, run setup first", tokenPath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to migrate token: %v", err)
+	}
+
+	if !migrated {
+		fmt.Printf("Token %s is already encrypted\n", tokenPath)
+		return nil
+	}
+	fmt.Printf("Encrypted token %s with the %s key\n", tokenPath, mode)
+	return nil
+}
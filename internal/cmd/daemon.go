@@ -0,0 +1,307 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/navaneethkn/cronocam/internal/auth"
+	"github.com/navaneethkn/cronocam/internal/config"
+	"github.com/navaneethkn/cronocam/internal/schedule"
+	"github.com/spf13/cobra"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run the upload jobs from the config on their schedules",
+	Long: `Run the jobs listed under daemon.jobs in the config file, each on its own
+cron schedule, without relying on the crontab of the system:
+
+  daemon:
+    jitter: 1m
+    jobs:
+      - name: camera
+        source: /photos/camera
+        schedule: "0 */2 * * *"
+        flags: ["--max-files=500", "--wait"]
+        quiet_hours: "08:00-18:00"
+
+Schedules use the standard five cron fields (minute, hour, day of month,
+month, day of week) or @hourly, @daily, @weekly and @monthly, evaluated in
+local time. flags are upload flags. Every source with a schedule is also
+uploaded by a job of its name, within its own quiet_hours. Jobs run one at
+a time in this process and share one authenticated client per profile, so
+the token saved by setup must exist. A job that becomes due while another
+one runs starts when it finishes.
+
+Jobs run as the profile given by --profile, or as the profile set in their
+profile field. Without --profile the scheduled sources of every profile
+are run too, as jobs named profile/source.
+
+Every run is delayed by a random jitter of up to daemon.jitter. Runs that
+would start during the quiet hours of their job are postponed until the
+quiet hours end. The next run of every job is logged.
+
+The daemon stops on SIGINT or SIGTERM once the running upload finished; a
+second signal stops it immediately.`,
+	Args: cobra.NoArgs,
+	RunE: runDaemon,
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+}
+
+// daemonJob is a configured job with its parsed schedule
+type daemonJob struct {
+	config.Job
+	schedule *schedule.Schedule
+	quiet    *schedule.Window
+	next     time.Time
+}
+
+// newDaemonJob parses the schedule, quiet hours and flags of a job so that
+// mistakes show up when the daemon starts rather than at the first run
+func newDaemonJob(job config.Job) (*daemonJob, error) {
+	sched, err := schedule.Parse(job.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("daemon job %s: %v", job.Name, err)
+	}
+	j := &daemonJob{Job: job, schedule: sched}
+
+	if job.QuietHours != "" {
+		if j.quiet, err = schedule.ParseWindow(job.QuietHours); err != nil {
+			return nil, fmt.Errorf("daemon job %s: invalid quiet_hours: %v", job.Name, err)
+		}
+	}
+	if _, err := j.command(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// command builds the upload command of a run with the job flags parsed
+func (j *daemonJob) command() (*cobra.Command, error) {
+	cmd := &cobra.Command{Use: "upload"}
+	addUploadFlags(cmd)
+	if err := cmd.ParseFlags(j.Flags); err != nil {
+		return nil, fmt.Errorf("daemon job %s: invalid flags: %v", j.Name, err)
+	}
+	if cmd.Flags().NArg() > 0 {
+		return nil, fmt.Errorf("daemon job %s: unexpected argument %q in flags, the directory is set as source", j.Name, cmd.Flags().Arg(0))
+	}
+	if cmd.Flags().Changed("dry-run") || cmd.Flags().Changed("output") {
+		return nil, fmt.Errorf("daemon job %s: --dry-run and --output cannot be used in jobs", j.Name)
+	}
+	if _, err := filterFromFlags(cmd); err != nil {
+		return nil, fmt.Errorf("daemon job %s: %v", j.Name, err)
+	}
+	return cmd, nil
+}
+
+// takeout reports whether the job uploads Google Takeout exports, whose
+// folders become albums
+func (j *daemonJob) takeout() bool {
+	cmd, err := j.command()
+	if err != nil {
+		return false
+	}
+	takeout, _ := cmd.Flags().GetBool("takeout")
+	return takeout
+}
+
+// scheduleNext sets the next run after now: the next match of the cron
+// expression plus jitter, moved to the end of the quiet hours if it falls
+// inside them. A zero time means the expression never matches.
+func (j *daemonJob) scheduleNext(now time.Time, jitter time.Duration) {
+	next := j.schedule.Next(now)
+	if !next.IsZero() {
+		if jitter > 0 {
+			next = next.Add(rand.N(jitter))
+		}
+		if j.quiet != nil && j.quiet.Contains(next) {
+			next = j.quiet.End(next)
+		}
+	}
+	j.next = next
+
+	if next.IsZero() {
+		slog.Warn("Job has no future run", "job", j.Name, "schedule", j.schedule)
+		return
+	}
+	slog.Info("Next run", "job", j.Name, "at", next.Format(time.RFC3339), "in", time.Until(next).Round(time.Second))
+}
+
+// nextDaemonJob returns the job that is due first, or nil if none has a
+// future run
+func nextDaemonJob(jobs []*daemonJob) *daemonJob {
+	var next *daemonJob
+	for _, job := range jobs {
+		if job.next.IsZero() {
+			continue
+		}
+		if next == nil || job.next.Before(next.next) {
+			next = job
+		}
+	}
+	return next
+}
+
+// daemonClient authenticates once for all jobs of a profile. Nobody is
+// around to finish the browser flow, so the token saved by setup is
+// required and has to grant the scopes of features.
+func daemonClient(ctx context.Context, features []string) (*http.Client, error) {
+	authenticator, err := newAuthenticator(features...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create authenticator: %v", err)
+	}
+	missing, err := authenticator.MissingScopes()
+	if err != nil {
+		return nil, fmt.Errorf("no usable token, run setup first: %v", err)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("token lacks %s for %s, run setup to authorize it", auth.ShortScope(missing[0].URL), missing[0].Feature)
+	}
+	client, err := authenticator.GetClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client: %v", err)
+	}
+	return client, nil
+}
+
+func runDaemon(cmd *cobra.Command, args []string) error {
+	configured, err := config.GetJobs()
+	if err != nil {
+		return err
+	}
+	if len(configured) == 0 {
+		return fmt.Errorf("no jobs configured in daemon.jobs and no source has a schedule")
+	}
+	var jobs []*daemonJob
+	for _, job := range configured {
+		j, err := newDaemonJob(job)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, j)
+	}
+
+	// Authenticate every profile the jobs run as up front, for the features
+	// of all its jobs
+	features := make(map[string][]string)
+	var profiles []string
+	for _, job := range jobs {
+		if _, ok := features[job.Profile]; !ok {
+			profiles = append(profiles, job.Profile)
+			features[job.Profile] = nil
+		}
+		if job.takeout() {
+			features[job.Profile] = append(features[job.Profile], auth.FeatureAlbums)
+		}
+	}
+	sessions := make(map[string]*uploadSession)
+	for _, profile := range profiles {
+		if err := config.SetProfile(profile); err != nil {
+			return err
+		}
+		client, err := daemonClient(context.Background(), features[profile])
+		if err != nil {
+			if profile != "" {
+				return fmt.Errorf("profile %s: %v", profile, err)
+			}
+			return err
+		}
+		sessions[profile] = &uploadSession{client: client}
+	}
+
+	stopMetrics, err := serveMetrics()
+	if err != nil {
+		return fmt.Errorf("failed to serve metrics: %v", err)
+	}
+	defer stopMetrics()
+
+	// The first signal lets a running upload finish. The handler is removed
+	// after it so a second signal terminates the process.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	stopping := make(chan struct{})
+	go func() {
+		sig, ok := <-signals
+		if !ok {
+			return
+		}
+		slog.Info("Stopping daemon", "signal", sig)
+		signal.Stop(signals)
+		close(stopping)
+	}()
+
+	jitter := config.GetDaemonJitter()
+	slog.Info("Daemon started", "jobs", len(jobs), "jitter", jitter)
+	for _, job := range jobs {
+		job.scheduleNext(time.Now(), jitter)
+	}
+
+	for {
+		job := nextDaemonJob(jobs)
+		if job == nil {
+			return fmt.Errorf("no job has a future run")
+		}
+
+		timer := time.NewTimer(time.Until(job.next))
+		select {
+		case <-stopping:
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		// A job delayed by a long run of another one can be due inside its
+		// quiet hours
+		if now := time.Now(); job.quiet != nil && job.quiet.Contains(now) {
+			job.next = job.quiet.End(now)
+			slog.Info("Postponing run until the end of quiet hours", "job", job.Name, "at", job.next.Format(time.RFC3339))
+			continue
+		}
+
+		runDaemonJob(job, sessions[job.Profile])
+
+		select {
+		case <-stopping:
+			return nil
+		default:
+		}
+		job.scheduleNext(time.Now(), jitter)
+	}
+}
+
+// runDaemonJob runs one upload of a job as its profile. Failures are
+// recorded like those of any upload and do not stop the daemon.
+func runDaemonJob(job *daemonJob, session *uploadSession) {
+	cmd, err := job.command()
+	if err == nil {
+		err = config.SetProfile(job.Profile)
+	}
+	if err != nil {
+		slog.Error("Job failed", "job", job.Name, "err", err)
+		return
+	}
+
+	slog.Info("Starting job", "job", job.Name)
+	start := time.Now()
+	var args []string
+	if job.Source != "" {
+		args = []string{job.Source}
+	}
+	if err := upload(cmd, args, session); err != nil {
+		slog.Error("Job failed", "job", job.Name, "duration", time.Since(start).Round(time.Second), "err", err)
+		return
+	}
+	slog.Info("Job finished", "job", job.Name, "duration", time.Since(start).Round(time.Second))
+}
@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/navaneethkn/cronocam/internal/config"
+	"github.com/navaneethkn/cronocam/internal/db"
+	"github.com/navaneethkn/cronocam/internal/filter"
+	"github.com/navaneethkn/cronocam/internal/uploader"
+	"github.com/spf13/cobra"
+)
+
+var errorsCmd = &cobra.Command{
+	Use:   "errors",
+	Short: "Browse recorded upload errors",
+	Long: `List upload errors with their category, attempt count and next retry,
+newest first. Errors can be filtered by age, path and category, and grouped
+by message to see which problems affect the most files.
+
+Use "errors clear" to delete the matching errors and "errors ignore" to hide
+the errors of known-bad files from reports and stop retrying them.`,
+	Args: cobra.NoArgs,
+	RunE: runErrors,
+}
+
+var errorsClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete the recorded errors matching the filters",
+	Long: `Delete the recorded errors matching --since, --path and --category, or
+all errors when no filter is given. Files whose errors are cleared are
+tried again by the next upload.`,
+	Args: cobra.NoArgs,
+	RunE: runErrorsClear,
+}
+
+var errorsIgnoreCmd = &cobra.Command{
+	Use:   "ignore <path>...",
+	Short: "Ignore the errors of files",
+	Long: `Hide the errors of files from "errors" and "status" and stop retrying
+them. "upload --force" still uploads ignored files.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runErrorsIgnore,
+}
+
+func init() {
+	rootCmd.AddCommand(errorsCmd)
+	errorsCmd.AddCommand(errorsClearCmd)
+	errorsCmd.AddCommand(errorsIgnoreCmd)
+
+	errorsCmd.PersistentFlags().String("since", "", "only errors since a duration ago (24h, 7d) or a date (YYYY-MM-DD or RFC 3339)")
+	errorsCmd.PersistentFlags().String("path", "", "only errors of this file or of files below this directory")
+	errorsCmd.PersistentFlags().String("category", "", "only errors of this category ("+categoryNames()+")")
+	errorsCmd.Flags().IntP("limit", "n", 50, "maximum number of errors to read (0 for unlimited)")
+	errorsCmd.Flags().BoolP("group", "g", false, "group errors by message")
+	errorsCmd.Flags().Bool("json", false, "print errors as JSON (same as --output json)")
+	addOutputFlag(errorsCmd)
+}
+
+// categoryNames lists the error categories for flag help and messages
+func categoryNames() string {
+	names := make([]string, len(uploader.Categories))
+	for i, c := range uploader.Categories {
+		names[i] = string(c)
+	}
+	return strings.Join(names, ", ")
+}
+
+// errorFilterFromFlags builds the error filter from the --since, --path and
+// --category flags
+func errorFilterFromFlags(cmd *cobra.Command) (db.ErrorFilter, error) {
+	var f db.ErrorFilter
+
+	if since, _ := cmd.Flags().GetString("since"); since != "" {
+		t, err := parseSince(since)
+		if err != nil {
+			return f, err
+		}
+		f.Since = t
+	}
+
+	if path, _ := cmd.Flags().GetString("path"); path != "" {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return f, fmt.Errorf("failed to get absolute path: %v", err)
+		}
+		f.Path = absPath
+	}
+
+	if category, _ := cmd.Flags().GetString("category"); category != "" {
+		valid := false
+		for _, c := range uploader.Categories {
+			if string(c) == category {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return f, fmt.Errorf("invalid category %q (expected one of %s)", category, categoryNames())
+		}
+		f.Category = category
+	}
+
+	return f, nil
+}
+
+// parseSince parses --since as a duration before now, with "d" accepted for
+// days, or as a date
+func parseSince(value string) (*time.Time, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			t := time.Now().AddDate(0, 0, -n)
+			return &t, nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		t := time.Now().Add(-d)
+		return &t, nil
+	}
+	t, err := filter.ParseDate(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --since %q (expected a duration such as 24h or 7d, or a date)", value)
+	}
+	return t, nil
+}
+
+// groupErrors groups errors by message, keeping the order in which each
+// message was first seen. Each path is listed once per group.
+func groupErrors(errors []db.UploadError) []errorGroupOutput {
+	groups := []errorGroupOutput{}
+	index := make(map[string]int)
+	seen := make(map[string]map[string]bool)
+
+	for _, e := range errors {
+		i, ok := index[e.Message]
+		if !ok {
+			i = len(groups)
+			index[e.Message] = i
+			seen[e.Message] = make(map[string]bool)
+			groups = append(groups, errorGroupOutput{
+				Message:   e.Message,
+				Category:  e.Category,
+				FirstSeen: e.Time,
+				LastSeen:  e.Time,
+			})
+		}
+
+		g := &groups[i]
+		g.Count++
+		if e.Time.Before(g.FirstSeen) {
+			g.FirstSeen = e.Time
+		}
+		if e.Time.After(g.LastSeen) {
+			g.LastSeen = e.Time
+		}
+		if !seen[e.Message][e.File] {
+			seen[e.Message][e.File] = true
+			g.Paths = append(g.Paths, e.File)
+		}
+	}
+	return groups
+}
+
+func runErrors(cmd *cobra.Command, args []string) error {
+	f, err := errorFilterFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	f.Limit, _ = cmd.Flags().GetInt("limit")
+	group, _ := cmd.Flags().GetBool("group")
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		format = outputJSON
+	}
+
+	database, err := db.New(config.GetDatabasePath())
+	if err != nil {
+		return fmt.Errorf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	errors, err := database.GetErrors(f)
+	if err != nil {
+		return fmt.Errorf("failed to get errors: %v", err)
+	}
+
+	if format != outputTable {
+		if group {
+			return writeOutput(format, groupErrors(errors))
+		}
+		return writeOutput(format, newErrorOutputs(errors))
+	}
+
+	if len(errors) == 0 {
+		fmt.Println("No errors recorded")
+		return nil
+	}
+
+	if group {
+		for _, g := range groupErrors(errors) {
+			fmt.Printf("%d error%s", g.Count, pluralize(g.Count))
+			if g.Category != "" {
+				fmt.Printf(" [%s]", g.Category)
+			}
+			fmt.Printf(", last seen %s:\n", formatRelativeTime(g.LastSeen))
+			fmt.Printf("  %s\n", g.Message)
+			for _, path := range g.Paths {
+				fmt.Printf("  - %s\n", path)
+			}
+			fmt.Println()
+		}
+		return nil
+	}
+
+	for _, e := range errors {
+		category := e.Category
+		if category == "" {
+			category = "unclassified"
+		}
+		fmt.Printf("%s  %-13s  attempt %d  %s\n",
+			e.Time.Local().Format("2006-01-02 15:04:05"), category, e.Attempt, e.File)
+		fmt.Printf("    %s\n", e.Message)
+		if e.NextRetryAt != nil {
+			fmt.Printf("    Next retry: %s\n", e.NextRetryAt.Local().Format("2006-01-02 15:04:05"))
+		}
+	}
+	return nil
+}
+
+func runErrorsClear(cmd *cobra.Command, args []string) error {
+	f, err := errorFilterFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	database, err := db.New(config.GetDatabasePath())
+	if err != nil {
+		return fmt.Errorf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	removed, err := database.DeleteErrors(f)
+	if err != nil {
+		return fmt.Errorf("failed to clear errors: %v", err)
+	}
+	fmt.Printf("Cleared %d error%s\n", removed, pluralize(int(removed)))
+	return nil
+}
+
+func runErrorsIgnore(cmd *cobra.Command, args []string) error {
+	database, err := db.New(config.GetDatabasePath())
+	if err != nil {
+		return fmt.Errorf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	for _, path := range args {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path: %v", err)
+		}
+		if err := database.IgnoreFile(absPath); err != nil {
+			return fmt.Errorf("failed to ignore %s: %v", absPath, err)
+		}
+		fmt.Printf("Ignoring errors of %s\n", absPath)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dustin/go-humanize"
+	"github.com/navaneethkn/cronocam/internal/filter"
+	"github.com/navaneethkn/cronocam/internal/takeout"
+	"github.com/spf13/cobra"
+)
+
+// addFilterFlags registers the file selection flags shared by upload and import
+func addFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().String("taken-after", "", "only include files taken on or after this date (YYYY-MM-DD or RFC 3339)")
+	cmd.Flags().String("taken-before", "", "only include files taken before this date (YYYY-MM-DD or RFC 3339)")
+	cmd.Flags().String("camera", "", "only include files whose camera make or model contains this text")
+	cmd.Flags().String("min-size", "", "only include files of at least this size (e.g. 100KB)")
+	cmd.Flags().String("max-size", "", "only include files of at most this size (e.g. 2GB)")
+	cmd.Flags().String("type", "", "only include files of this type (image or video)")
+}
+
+// filterFromFlags builds a file filter from the flags added by addFilterFlags
+func filterFromFlags(cmd *cobra.Command) (*filter.Filter, error) {
+	opts, err := filterOptionsFromFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return filter.New(opts)
+}
+
+// filterOptionsFromFlags reads the flags added by addFilterFlags
+func filterOptionsFromFlags(cmd *cobra.Command) (filter.Options, error) {
+	var opts filter.Options
+	var err error
+
+	if value, _ := cmd.Flags().GetString("taken-after"); value != "" {
+		if opts.TakenAfter, err = filter.ParseDate(value); err != nil {
+			return opts, fmt.Errorf("invalid --taken-after: %v", err)
+		}
+	}
+	if value, _ := cmd.Flags().GetString("taken-before"); value != "" {
+		if opts.TakenBefore, err = filter.ParseDate(value); err != nil {
+			return opts, fmt.Errorf("invalid --taken-before: %v", err)
+		}
+	}
+	if value, _ := cmd.Flags().GetString("min-size"); value != "" {
+		size, err := humanize.ParseBytes(value)
+		if err != nil {
+			return opts, fmt.Errorf("invalid --min-size: %v", err)
+		}
+		opts.MinSize = int64(size)
+	}
+	if value, _ := cmd.Flags().GetString("max-size"); value != "" {
+		size, err := humanize.ParseBytes(value)
+		if err != nil {
+			return opts, fmt.Errorf("invalid --max-size: %v", err)
+		}
+		opts.MaxSize = int64(size)
+	}
+	opts.Camera, _ = cmd.Flags().GetString("camera")
+	opts.MediaType, _ = cmd.Flags().GetString("type")
+
+	return opts, nil
+}
+
+// inspectFile reads the file metadata and applies the filter, returning
+// the reason the filter excludes the file if it does. The cheap stat-based
+// checks run first so excluded files are never opened. In Takeout mode the
+// sidecar is read before the metadata checks so its capture time can stand
+// in for missing EXIF data.
+func inspectFile(f *filter.Filter, sidecars *takeout.Index, path string, info os.FileInfo) (*scannedFile, string) {
+	if ok, reason := f.MatchFile(path, info); !ok {
+		return nil, reason
+	}
+
+	meta := readFileMetadata(path)
+	sidecar := readTakeoutSidecar(sidecars, path, meta)
+	if ok, reason := f.MatchMetadata(meta, info); !ok {
+		return nil, reason
+	}
+
+	return &scannedFile{path: path, info: info, meta: meta, sidecar: sidecar}, ""
+}
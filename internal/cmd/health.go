@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/navaneethkn/cronocam/internal/auth"
+	"github.com/navaneethkn/cronocam/internal/config"
+	"github.com/navaneethkn/cronocam/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// heartbeatSchemaVersion is reported in the heartbeat file
+const heartbeatSchemaVersion = 1
+
+var healthCmd = &cobra.Command{
+	Use:   "health",
+	Short: "Check that unattended runs are succeeding",
+	Long: `Check the heartbeat file written after every upload and import, and the
+stored OAuth token. The command exits with a non-zero status when the last
+successful run is older than --max-age, the token cannot be used, or more
+than --max-error-rate of the files tried by the last run failed.
+
+Use it as a systemd ExecCondition, a Nagios check or a healthcheck-style
+monitor to notice that scheduled uploads stopped working.`,
+	Args: cobra.NoArgs,
+	RunE: runHealth,
+}
+
+func init() {
+	rootCmd.AddCommand(healthCmd)
+
+	healthCmd.Flags().Duration("max-age", 24*time.Hour, "maximum age of the last successful run")
+	healthCmd.Flags().Float64("max-error-rate", 0.25, "maximum share of files tried by the last run that may fail (0 to 1)")
+	addOutputFlag(healthCmd)
+}
+
+// writeHeartbeat replaces the heartbeat file with the state after a run
+func writeHeartbeat(database *db.DB, run *db.Run) {
+	hostname, _ := os.Hostname()
+	heartbeat := heartbeatOutput{
+		SchemaVersion: heartbeatSchemaVersion,
+		UpdatedAt:     time.Now().UTC().Truncate(time.Second),
+		Hostname:      hostname,
+		LastRun:       newRunOutput(run),
+		Auth:          readAuthState(),
+	}
+
+	if run.ExitStatus != nil && *run.ExitStatus == 0 {
+		heartbeat.LastSuccessRun = heartbeat.LastRun
+	} else if lastSuccess, err := database.GetLastSuccessfulRun(); err != nil {
+		slog.Error("Failed to get last successful run", "err", err)
+	} else if lastSuccess != nil {
+		heartbeat.LastSuccessRun = newRunOutput(lastSuccess)
+	}
+
+	if pending, err := database.GetPendingCounts(time.Now()); err != nil {
+		slog.Error("Failed to count pending files", "err", err)
+	} else {
+		heartbeat.Pending = pendingOutput{
+			Imported:          pending.Imported,
+			RetryDue:          pending.RetryDue,
+			RetryScheduled:    pending.RetryScheduled,
+			FailedPermanently: pending.FailedPermanently,
+		}
+	}
+
+	path := config.GetHeartbeatPath()
+	if err := writeJSONFile(path, heartbeat); err != nil {
+		slog.Error("Failed to write heartbeat", "path", path, "err", err)
+	}
+}
+
+// writeJSONFile replaces path with v as JSON. The file is written under a
+// temporary name and renamed so readers never see a partial file.
+func writeJSONFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// readHeartbeat reads the heartbeat file
+func readHeartbeat(path string) (*heartbeatOutput, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var heartbeat heartbeatOutput
+	if err := json.Unmarshal(data, &heartbeat); err != nil {
+		return nil, fmt.Errorf("invalid heartbeat file: %v", err)
+	}
+	return &heartbeat, nil
+}
+
+// checkHealth evaluates the heartbeat and the auth state
+func checkHealth(heartbeat *heartbeatOutput, heartbeatErr error, authState authOutput, maxAge time.Duration, maxErrorRate float64) *healthOutput {
+	out := &healthOutput{Healthy: true, Checks: []healthCheckOutput{}}
+	add := func(name string, ok bool, format string, args ...any) {
+		out.Checks = append(out.Checks, healthCheckOutput{Name: name, OK: ok, Message: fmt.Sprintf(format, args...)})
+		if !ok {
+			out.Healthy = false
+		}
+	}
+
+	switch {
+	case heartbeatErr != nil && os.IsNotExist(heartbeatErr):
+		add("last_success", false, "no heartbeat at %s, no upload or import has finished yet", config.GetHeartbeatPath())
+	case heartbeatErr != nil:
+		add("last_success", false, "%v", heartbeatErr)
+	case heartbeat.LastSuccessRun == nil || heartbeat.LastSuccessRun.FinishedAt == nil:
+		add("last_success", false, "no successful run recorded")
+	default:
+		finished := *heartbeat.LastSuccessRun.FinishedAt
+		age := time.Since(finished)
+		add("last_success", age <= maxAge, "last successful run %d finished %s (maximum age %s)",
+			heartbeat.LastSuccessRun.ID, formatRelativeTime(finished), maxAge)
+	}
+
+	switch authState.State {
+	case auth.TokenValid, auth.TokenRefreshable:
+		if len(authState.MissingScopes) > 0 {
+			add("auth", false, "token lacks %s, run setup to authorize it", strings.Join(authState.MissingScopes, ", "))
+			break
+		}
+		add("auth", true, "token is %s", authState.State)
+	case auth.TokenInvalid:
+		if authState.Encrypted {
+			add("auth", false, "token cannot be decrypted, check token.encryption and its key")
+			break
+		}
+		add("auth", false, "token is %s, run setup to authenticate again", authState.State)
+	default:
+		add("auth", false, "token is %s, run setup to authenticate again", authState.State)
+	}
+
+	if heartbeat != nil && heartbeat.LastRun != nil {
+		run := heartbeat.LastRun
+		tried := run.FilesUploaded + run.FilesFailed
+		if tried == 0 {
+			add("error_rate", true, "last run %d tried no files", run.ID)
+		} else {
+			rate := float64(run.FilesFailed) / float64(tried)
+			add("error_rate", rate <= maxErrorRate, "%d of %d files failed in run %d (%.0f%%, maximum %.0f%%)",
+				run.FilesFailed, tried, run.ID, rate*100, maxErrorRate*100)
+		}
+	}
+
+	return out
+}
+
+func runHealth(cmd *cobra.Command, args []string) error {
+	maxAge, _ := cmd.Flags().GetDuration("max-age")
+	maxErrorRate, _ := cmd.Flags().GetFloat64("max-error-rate")
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	heartbeat, heartbeatErr := readHeartbeat(config.GetHeartbeatPath())
+	health := checkHealth(heartbeat, heartbeatErr, readAuthState(), maxAge, maxErrorRate)
+
+	if format != outputTable {
+		if err := writeOutput(format, health); err != nil {
+			return err
+		}
+	} else {
+		for _, check := range health.Checks {
+			status := "OK  "
+			if !check.OK {
+				status = "FAIL"
+			}
+			fmt.Printf("%s %-12s %s\n", status, check.Name, check.Message)
+		}
+	}
+
+	if !health.Healthy {
+		failed := 0
+		for _, check := range health.Checks {
+			if !check.OK {
+				failed++
+			}
+		}
+		cmd.SilenceUsage = true
+		return fmt.Errorf("unhealthy: %d check%s failed", failed, pluralize(failed))
+	}
+	return nil
+}
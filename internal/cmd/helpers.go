@@ -3,13 +3,20 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"maps"
+	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 
 	"github.com/navaneethkn/cronocam/internal/auth"
 	"github.com/navaneethkn/cronocam/internal/config"
 	"github.com/navaneethkn/cronocam/internal/db"
+	"github.com/navaneethkn/cronocam/internal/filter"
+	"github.com/navaneethkn/cronocam/internal/metadata"
+	"github.com/navaneethkn/cronocam/internal/pairing"
+	"github.com/navaneethkn/cronocam/internal/takeout"
 	"github.com/navaneethkn/cronocam/internal/uploader"
 )
 
@@ -24,19 +31,119 @@ func printPaths() error {
 		return fmt.Errorf("failed to get absolute database path: %v", err)
 	}
 
+	if profile := config.GetProfile(); profile != "" {
+		fmt.Printf("Profile: %s\n", profile)
+	}
 	fmt.Printf("Credentials path: %s\n", credentialsPath)
 	fmt.Printf("Database path: %s\n\n", databasePath)
 	return nil
 }
 
-func setupAuth() error {
+// newAuthenticator creates the authenticator of the selected profile. The
+// token has to authorize the features turned on in the config and the
+// extra features of the run.
+func newAuthenticator(extra ...string) (*auth.Authenticator, error) {
+	key, err := tokenKey()
+	if err != nil {
+		return nil, err
+	}
+	configured, err := authFeatures()
+	if err != nil {
+		return nil, err
+	}
+	features := slices.Clone(extra)
+	for feature := range configured {
+		features = append(features, feature)
+	}
+	return auth.New(config.GetCredentialsPath(), config.GetTokenPath(), key, features)
+}
+
+// authFeatures returns the features beyond uploading that the config of
+// the selected profile turns on, with the setting that turns them on
+func authFeatures() (map[string]string, error) {
+	features := make(map[string]string)
+	livePhotoVideo, err := config.GetLivePhotoVideo()
+	if err != nil {
+		return nil, err
+	}
+	if livePhotoVideo == config.LivePhotoVideoAlbum {
+		features[auth.FeatureAlbums] = "live_photos.video is album"
+	}
+	sources, err := config.GetSources()
+	if err != nil {
+		return nil, err
+	}
+	for _, source := range sources {
+		if source.AlbumPolicy != config.AlbumPolicyNone {
+			features[auth.FeatureAlbums] = fmt.Sprintf("source %s has album policy %s", source.Name, source.AlbumPolicy)
+			break
+		}
+	}
+	return features, nil
+}
+
+// tokenKey returns the key the token is encrypted with, or nil when
+// token.encryption is none
+func tokenKey() (*auth.TokenKey, error) {
+	mode, err := config.GetTokenEncryption()
+	if err != nil {
+		return nil, err
+	}
+	switch mode {
+	case config.TokenEncryptionPassphrase:
+		passphrase, err := config.GetTokenPassphrase()
+		if err != nil {
+			return nil, err
+		}
+		return auth.PassphraseKey(passphrase)
+	case config.TokenEncryptionMachineID:
+		return auth.MachineIDKey(config.GetMachineIDFile())
+	}
+	return nil, nil
+}
+
+// setupAuth authorizes the token of the selected profile, explaining the
+// requested scopes. extra maps features not turned on in the config to why
+// they are requested.
+func setupAuth(extra map[string]string) error {
 	ctx := context.Background()
-	authenticator, err := auth.New(config.GetCredentialsPath())
+	features, err := authFeatures()
+	if err != nil {
+		return err
+	}
+	for feature, why := range extra {
+		if _, ok := features[feature]; !ok {
+			features[feature] = why
+		}
+	}
+	authenticator, err := newAuthenticator(slices.Collect(maps.Keys(features))...)
 	if err != nil {
 		return fmt.Errorf("failed to create authenticator: %v", err)
 	}
 
-	_, err = authenticator.GetClient(ctx)
+	fmt.Println("Requesting access to Google Photos:")
+	for _, scope := range authenticator.Scopes() {
+		fmt.Printf("  %s\n      %s", auth.ShortScope(scope.URL), scope.Reason)
+		if why, ok := features[scope.Feature]; ok {
+			fmt.Printf(" (%s)", why)
+		}
+		fmt.Println()
+	}
+
+	missing, err := authenticator.MissingScopes()
+	switch {
+	case err != nil:
+		// No usable token yet; GetClient authorizes
+	case len(missing) == 0:
+		fmt.Println("The stored token already grants this access.")
+	default:
+		for _, scope := range missing {
+			fmt.Printf("The stored token lacks %s, which %s needs.\n", auth.ShortScope(scope.URL), scope.Feature)
+		}
+		fmt.Println("Authorizing again.")
+	}
+
+	_, err = authenticator.Authorize(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get client: %v", err)
 	}
@@ -44,94 +151,364 @@ func setupAuth() error {
 	return nil
 }
 
-// uploadFiles uploads a specific list of files
-func uploadFiles(files []string, force bool, maxFiles int64) error {
-	ctx := context.Background()
-
-	// Initialize authenticator
-	authenticator, err := auth.New(config.GetCredentialsPath())
+// readFileMetadata extracts capture metadata from a file. Failures are logged
+// and result in empty metadata so they never stop the scan.
+func readFileMetadata(path string) *metadata.Metadata {
+	meta, err := metadata.Extract(path)
 	if err != nil {
-		return fmt.Errorf("failed to create authenticator: %v", err)
+		if err != metadata.ErrUnsupportedFormat {
+			slog.Warn("Failed to read metadata", "path", path, "err", err)
+		}
+		return &metadata.Metadata{}
 	}
+	return meta
+}
 
-	// Get OAuth2 client
-	client, err := authenticator.GetClient(ctx)
+// saveFileMetadata stores the capture metadata of a scanned file
+func saveFileMetadata(database *db.DB, path, hash string, meta *metadata.Metadata) {
+	err := database.SaveFileMetadata(&db.FileMetadata{
+		FilePath:    path,
+		FileHash:    hash,
+		TakenAt:     meta.TakenAt,
+		CameraMake:  meta.CameraMake,
+		CameraModel: meta.CameraModel,
+		Width:       meta.Width,
+		Height:      meta.Height,
+		Latitude:    meta.Latitude,
+		Longitude:   meta.Longitude,
+		ContentID:   meta.ContentID,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get client: %v", err)
+		slog.Error("Failed to save metadata", "path", path, "err", err)
+	}
+}
+
+// describeFile renders the media item description for a file. Paths are made
+// relative to root when one is given. Render failures are logged and leave
+// the description empty.
+func describeFile(tmpl *uploader.DescriptionTemplate, root, path string, info os.FileInfo, meta *metadata.Metadata) string {
+	relPath := path
+	if root != "" {
+		if rel, err := filepath.Rel(root, path); err == nil {
+			relPath = rel
+		}
+	}
+	hostname, _ := os.Hostname()
+
+	data := uploader.DescriptionData{
+		Path:         path,
+		RelativePath: relPath,
+		Folder:       filepath.Base(filepath.Dir(path)),
+		Filename:     filepath.Base(path),
+		TakenAt:      meta.CaptureTime(info.ModTime()),
+		Hostname:     hostname,
+	}
+	if meta != nil {
+		data.CameraMake = meta.CameraMake
+		data.CameraModel = meta.CameraModel
 	}
 
-	// Initialize database
-	database, err := db.New(config.GetDatabasePath())
+	description, err := tmpl.Render(data)
 	if err != nil {
-		return fmt.Errorf("failed to initialize database: %v", err)
+		slog.Warn("Failed to render description", "path", path, "err", err)
+		return ""
+	}
+	return description
+}
+
+// uploadOptions holds the settings shared by the upload modes
+type uploadOptions struct {
+	force    bool
+	maxFiles int64
+	filter   *filter.Filter
+	// takeout pairs media with Google Takeout JSON sidecars
+	takeout bool
+	// livePhotoVideo is the config.LivePhotoVideo* policy for Live Photos
+	livePhotoVideo string
+	// rawPolicy is the config.RawPolicy* policy for RAW+JPEG pairs
+	rawPolicy string
+	// retryFailed retries failed files regardless of their retry schedule
+	retryFailed bool
+	// stats collects the counts recorded in the run history
+	stats runCounts
+	// plan collects what a dry run would do; nil for real uploads
+	plan *uploadPlan
+	// retried holds the due retries already processed in this run, which
+	// the directory walk or file list reaches again
+	retried map[string]bool
+	// client is an already authenticated client to upload with, or nil to
+	// authenticate for this upload
+	client *http.Client
+	// sources are the configured sources, whose settings apply to listed
+	// and retried files below them
+	sources []config.Source
+}
+
+// reasonAlreadyUploaded is the skip reason of files whose content is
+// already uploaded
+const reasonAlreadyUploaded = "already uploaded"
+
+// dryRun reports whether files are only planned, not uploaded
+func (o uploadOptions) dryRun() bool {
+	return o.plan != nil
+}
+
+// handled reports whether path was already processed in this run, so that
+// it is neither counted nor uploaded twice
+func (o uploadOptions) handled(path string) bool {
+	return o.retried[path] || o.plan.has(path)
+}
+
+// skip leaves a file out of the run
+func (o uploadOptions) skip(path, reason string) {
+	slog.Info("Skipping file", "path", path, "reason", reason)
+	o.stats.skipped()
+	o.plan.skip(path, reason)
+}
+
+// fail records a failed step of a file. Real runs store it in the error log,
+// schedule a retry and run the failure hook, dry runs list it in the plan.
+func (o uploadOptions) fail(database *db.DB, path, message string, err error) {
+	slog.Error(message, "path", path, "err", err)
+	o.stats.failed()
+	if o.plan != nil {
+		o.plan.fail(path, message, err)
+		return
+	}
+	failureHook(saveUploadError(database, path, message, err))
+}
+
+// scannedFile describes a file that passed the filters along with everything
+// learned about it while scanning
+type scannedFile struct {
+	path    string
+	info    os.FileInfo
+	meta    *metadata.Metadata
+	sidecar *takeout.Sidecar
+	pair    *pairing.Pair
+}
+
+// mediaItemOptions builds the description and album of an upload. A
+// description from a Takeout sidecar takes precedence over the template,
+// and Takeout and Live Photo albums over the album policy of the source.
+func mediaItemOptions(ctx context.Context, database *db.DB, photoUploader *uploader.Uploader, descTemplate *uploader.DescriptionTemplate, source *config.Source, file *scannedFile, opts uploadOptions) (uploader.UploadOptions, error) {
+	root := ""
+	if source != nil {
+		root = source.Path
+	}
+	itemOpts := uploader.UploadOptions{
+		Description: describeFile(descTemplate, root, file.path, file.info, file.meta),
+	}
+
+	if file.sidecar != nil && file.sidecar.Description != "" {
+		itemOpts.Description = file.sidecar.Description
+	}
+
+	if album := albumOf(source, file, opts); album != "" {
+		albumID, err := ensureAlbum(ctx, database, photoUploader, album)
+		if err != nil {
+			return itemOpts, err
+		}
+		itemOpts.AlbumID = albumID
+	}
+	return itemOpts, nil
+}
+
+// albumOf returns the title of the album a file belongs in, or an empty
+// string
+func albumOf(source *config.Source, file *scannedFile, opts uploadOptions) string {
+	album := ""
+	if file.sidecar != nil {
+		album = file.sidecar.Album
+	}
+	if album == "" && file.pair != nil && file.pair.Kind == pairing.LivePhoto && opts.livePhotoVideo == config.LivePhotoVideoAlbum {
+		album = config.GetLivePhotoAlbum()
+	}
+	if album == "" && source != nil {
+		album = source.AlbumFor(file.path)
+	}
+	return album
+}
+
+// newPhotoUploader authenticates, unless a client is passed in, and creates
+// the uploader. Dry runs never touch the network, so they get an uploader
+// without a client that is only used to check and hash files.
+func newPhotoUploader(ctx context.Context, opts uploadOptions) (*uploader.Uploader, error) {
+	client := opts.client
+	if client == nil && opts.plan == nil {
+		// Initialize authenticator. Takeout folders become albums.
+		var features []string
+		if opts.takeout {
+			features = append(features, auth.FeatureAlbums)
+		}
+		authenticator, err := newAuthenticator(features...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create authenticator: %v", err)
+		}
+
+		// Get OAuth2 client
+		client, err = authenticator.GetClient(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get client: %v", err)
+		}
 	}
-	defer database.Close()
 
 	// Initialize uploader with configuration
 	photoUploader, err := uploader.New(client, uploader.Config{
-		ChunkSize:          config.GetChunkSize(),
-		MaxRetries:         config.GetMaxRetries(),
-		RequestsPerSecond:  config.GetRequestsPerSecond(),
-		MaxBurst:           config.GetMaxBurst(),
+		ChunkSize:         config.GetChunkSize(),
+		MaxRetries:        config.GetMaxRetries(),
+		RequestsPerSecond: config.GetRequestsPerSecond(),
+		MaxBurst:          config.GetMaxBurst(),
 	})
 	if err != nil {
-		return fmt.Errorf("failed to create uploader: %v", err)
+		return nil, fmt.Errorf("failed to create uploader: %v", err)
+	}
+	return photoUploader, nil
+}
+
+// uploadFiles uploads a specific list of files
+func uploadFiles(database *db.DB, files []string, opts uploadOptions) error {
+	ctx := context.Background()
+
+	photoUploader, err := newPhotoUploader(ctx, opts)
+	if err != nil {
+		return err
+	}
+	opts.stats.progress.countFiles(files)
+
+	// Parse the media item description template
+	descTemplate, err := uploader.NewDescriptionTemplate(config.GetDescriptionTemplate())
+	if err != nil {
+		return err
 	}
 
-	// Track number of uploads and failures
-	uploadCount := int64(0)
+	// Index Takeout sidecars when migrating from a Takeout export
+	var sidecars *takeout.Index
+	if opts.takeout {
+		sidecars = takeout.NewIndex()
+	}
+
+	// Detect paired files while scanning
+	pairs := pairing.NewDetector(config.GetSupportedRaw())
+
+	// Track number of failures
 	failureCount := int64(0)
 
+	// Move or delete uploaded files once all were scanned
+	actions := newPostUploadActions()
+	defer actions.apply()
+
 	// Process each file
 	for _, path := range files {
 		// Skip if max files reached
-		if maxFiles > 0 && uploadCount >= maxFiles {
-			log.Printf("Reached upload limit of %d files", maxFiles)
+		if opts.maxFiles > 0 && opts.stats.FilesUploaded >= opts.maxFiles {
+			slog.Info("Reached upload limit", "max_files", opts.maxFiles)
 			break
 		}
 
+		// Due retries were processed before the list
+		if opts.handled(path) {
+			continue
+		}
+
+		// Like in a directory walk, only media files count as scanned
 		if !photoUploader.IsSupportedFile(path) {
-			log.Printf("Skipping unsupported file: %s", path)
+			slog.Info("Skipping file", "path", path, "reason", "unsupported file type")
+			opts.plan.skip(path, "unsupported file type")
+			continue
+		}
+		opts.stats.scanned(path)
+
+		// Leave permanent failures and scheduled retries alone
+		if reason := skipByRetrySchedule(database, path, opts); reason != "" {
+			opts.skip(path, reason)
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			opts.fail(database, path, "Failed to access file", err)
+			failureCount++
+			continue
+		}
+
+		// Apply filters before hashing
+		file, reason := inspectFile(opts.filter, sidecars, path, info)
+		if file == nil {
+			opts.skip(path, reason)
+			continue
+		}
+
+		// Apply the Live Photo and RAW+JPEG policies
+		detectPair(pairs, file)
+		if !opts.dryRun() {
+			savePair(database, file)
+		}
+		if reason := skipByPairPolicy(pairs, file, opts); reason != "" {
+			opts.skip(path, reason)
+			// Not uploaded, so it may only be moved with its uploaded half
+			if !opts.dryRun() {
+				actions.addLeftOut(sourceOf(opts.sources, path), file)
+			}
 			continue
 		}
 
 		// Calculate file hash
 		hash, err := photoUploader.CalculateFileHash(path)
 		if err != nil {
-			log.Printf("Failed to calculate hash for %s: %v", path, err)
-			if err := database.SaveUploadError(path, fmt.Sprintf("Failed to calculate hash: %v", err)); err != nil {
-				log.Printf("Failed to save error record: %v", err)
-			}
+			opts.fail(database, path, "Failed to calculate hash", err)
 			failureCount++
 			continue
 		}
 
+		// Record capture metadata
+		if !opts.dryRun() {
+			saveFileMetadata(database, path, hash, file.meta)
+		}
+
 		// Check if already uploaded
-		if !force {
+		if !opts.force {
 			uploaded, err := database.IsFileUploaded(hash)
 			if err != nil {
-				log.Printf("Failed to check upload status for %s: %v", path, err)
-				if err := database.SaveUploadError(path, fmt.Sprintf("Failed to check upload status: %v", err)); err != nil {
-					log.Printf("Failed to save error record: %v", err)
-				}
+				opts.fail(database, path, "Failed to check upload status", err)
 				failureCount++
 				continue
 			}
 
 			if uploaded {
-				log.Printf("Skipping %s (already uploaded)", path)
+				opts.skip(path, reasonAlreadyUploaded)
+				// Its content is safe, e.g. when a pair was not complete
+				// after an earlier run
+				if !opts.dryRun() {
+					source := sourceOf(opts.sources, path)
+					addUploadedToAlbum(ctx, database, photoUploader, source, file, hash, opts)
+					actions.add(source, file)
+				}
 				continue
 			}
 		}
 
+		// A dry run stops here, before anything is sent
+		if opts.plan != nil {
+			opts.plan.upload(path, hash, info.Size())
+			continue
+		}
+
+		// Build description and album
+		source := sourceOf(opts.sources, path)
+		itemOpts, err := mediaItemOptions(ctx, database, photoUploader, descTemplate, source, file, opts)
+		if err != nil {
+			opts.fail(database, path, "Failed to prepare upload", err)
+			failureCount++
+			continue
+		}
+
 		// Upload file
-		log.Printf("Uploading %s...", path)
-		googleID, err := photoUploader.UploadFile(ctx, path)
+		itemOpts.Progress = opts.stats.progress.chunk
+		slog.Info("Uploading", "path", path)
+		googleID, err := photoUploader.UploadFile(ctx, path, itemOpts)
 		if err != nil {
-			log.Printf("Failed to upload %s: %v", path, err)
-			if err := database.SaveUploadError(path, fmt.Sprintf("Failed to upload: %v", err)); err != nil {
-				log.Printf("Failed to save error record: %v", err)
-			}
+			opts.fail(database, path, "Failed to upload", err)
 			failureCount++
 			continue
 		}
@@ -143,61 +520,58 @@ func uploadFiles(files []string, force bool, maxFiles int64) error {
 			GoogleID: googleID,
 		})
 		if err != nil {
-			log.Printf("Failed to save upload record for %s: %v", path, err)
-			if err := database.SaveUploadError(path, fmt.Sprintf("Failed to save upload record: %v", err)); err != nil {
-				log.Printf("Failed to save error record: %v", err)
-			}
+			opts.fail(database, path, "Failed to save upload record", err)
 			failureCount++
 			continue
 		}
 
-		log.Printf("Successfully uploaded %s", path)
-		uploadCount++
+		slog.Info("Uploaded", "path", path, "size", info.Size())
+		opts.stats.uploaded(info.Size())
+		uploadHook(path, info.Size(), hash, googleID)
+		if itemOpts.AlbumID != "" {
+			if err := database.SaveAlbumItem(itemOpts.AlbumID, googleID); err != nil {
+				slog.Error("Failed to save album item", "path", path, "err", err)
+			}
+		}
+		actions.add(source, file)
 	}
 
-	// Return error if any uploads failed
-	if failureCount > 0 {
+	// Return error if any uploads failed. Dry runs list failures in the plan.
+	if failureCount > 0 && !opts.dryRun() {
 		return fmt.Errorf("%d upload(s) failed", failureCount)
 	}
 
 	return nil
 }
 
-func uploadPhotos(recursive, force bool, maxFiles int64) error {
+// uploadPhotos uploads the supported files found under the path of a source
+func uploadPhotos(database *db.DB, source *config.Source, recursive bool, opts uploadOptions) error {
 	ctx := context.Background()
 
-	// Initialize authenticator
-	authenticator, err := auth.New(config.GetCredentialsPath())
+	photoUploader, err := newPhotoUploader(ctx, opts)
 	if err != nil {
-		return fmt.Errorf("failed to create authenticator: %v", err)
+		return err
 	}
+	opts.stats.progress.countTree(source.Path, recursive, photoUploader.IsSupportedFile)
 
-	// Get OAuth2 client
-	client, err := authenticator.GetClient(ctx)
+	// Parse the media item description template
+	descTemplate, err := uploader.NewDescriptionTemplate(config.GetDescriptionTemplate())
 	if err != nil {
-		return fmt.Errorf("failed to get client: %v", err)
+		return err
 	}
 
-	// Initialize database
-	database, err := db.New(config.GetDatabasePath())
-	if err != nil {
-		return fmt.Errorf("failed to initialize database: %v", err)
+	// Index Takeout sidecars when migrating from a Takeout export
+	var sidecars *takeout.Index
+	if opts.takeout {
+		sidecars = takeout.NewIndex()
 	}
-	defer database.Close()
 
-	// Initialize uploader with configuration
-	photoUploader, err := uploader.New(client, uploader.Config{
-		ChunkSize:          config.GetChunkSize(),
-		MaxRetries:         config.GetMaxRetries(),
-		RequestsPerSecond:  config.GetRequestsPerSecond(),
-		MaxBurst:           config.GetMaxBurst(),
-	})
-	if err != nil {
-		return fmt.Errorf("failed to create uploader: %v", err)
-	}
+	// Detect paired files while scanning
+	pairs := pairing.NewDetector(config.GetSupportedRaw())
 
-	// Track number of files uploaded
-	var uploadCount int64
+	// Move or delete uploaded files once the walk is done
+	actions := newPostUploadActions()
+	defer actions.apply()
 
 	// Walk function for processing files
 	walkFn := func(path string, info os.FileInfo, err error) error {
@@ -206,13 +580,13 @@ func uploadPhotos(recursive, force bool, maxFiles int64) error {
 		}
 
 		// Check if we've hit the upload limit
-		if maxFiles > 0 && uploadCount >= maxFiles {
+		if opts.maxFiles > 0 && opts.stats.FilesUploaded >= opts.maxFiles {
 			return filepath.SkipAll
 		}
 
 		// Skip directories if not recursive
 		if info.IsDir() {
-			if !recursive && path != config.GetUploadPath() {
+			if !recursive && path != source.Path {
 				return filepath.SkipDir
 			}
 			return nil
@@ -222,32 +596,90 @@ func uploadPhotos(recursive, force bool, maxFiles int64) error {
 			return nil
 		}
 
+		// Due retries were processed before the walk
+		if opts.handled(path) {
+			return nil
+		}
+		opts.stats.scanned(path)
+
+		// Leave permanent failures and scheduled retries alone
+		if reason := skipByRetrySchedule(database, path, opts); reason != "" {
+			opts.skip(path, reason)
+			return nil
+		}
+
+		// Apply filters before hashing
+		file, reason := inspectFile(opts.filter, sidecars, path, info)
+		if file == nil {
+			opts.skip(path, reason)
+			return nil
+		}
+
+		// Apply the Live Photo and RAW+JPEG policies
+		detectPair(pairs, file)
+		if !opts.dryRun() {
+			savePair(database, file)
+		}
+		if reason := skipByPairPolicy(pairs, file, opts); reason != "" {
+			opts.skip(path, reason)
+			// Not uploaded, so it may only be moved with its uploaded half
+			if !opts.dryRun() {
+				actions.addLeftOut(source, file)
+			}
+			return nil
+		}
+
 		// Calculate file hash
 		hash, err := photoUploader.CalculateFileHash(path)
 		if err != nil {
-			log.Printf("Failed to calculate hash for %s: %v", path, err)
+			opts.fail(database, path, "Failed to calculate hash", err)
 			return nil
 		}
 
+		// Record capture metadata
+		if !opts.dryRun() {
+			saveFileMetadata(database, path, hash, file.meta)
+		}
+
 		// Check if file was already uploaded
-		if !force {
+		if !opts.force {
 			uploaded, err := database.IsFileUploaded(hash)
 			if err != nil {
-				log.Printf("Failed to check upload status for %s: %v", path, err)
+				opts.fail(database, path, "Failed to check upload status", err)
 				return nil
 			}
 
 			if uploaded {
-				log.Printf("Skipping %s (already uploaded)", path)
+				opts.skip(path, reasonAlreadyUploaded)
+				// Its content is safe, e.g. when a pair was not complete
+				// after an earlier run
+				if !opts.dryRun() {
+					addUploadedToAlbum(ctx, database, photoUploader, source, file, hash, opts)
+					actions.add(source, file)
+				}
 				return nil
 			}
 		}
 
+		// A dry run stops here, before anything is sent
+		if opts.plan != nil {
+			opts.plan.upload(path, hash, info.Size())
+			return nil
+		}
+
+		// Build description and album
+		itemOpts, err := mediaItemOptions(ctx, database, photoUploader, descTemplate, source, file, opts)
+		if err != nil {
+			opts.fail(database, path, "Failed to prepare upload", err)
+			return nil
+		}
+
 		// Upload file
-		log.Printf("Uploading %s...", path)
-		googleID, err := photoUploader.UploadFile(ctx, path)
+		itemOpts.Progress = opts.stats.progress.chunk
+		slog.Info("Uploading", "path", path)
+		googleID, err := photoUploader.UploadFile(ctx, path, itemOpts)
 		if err != nil {
-			log.Printf("Failed to upload %s: %v", path, err)
+			opts.fail(database, path, "Failed to upload", err)
 			return nil
 		}
 
@@ -258,21 +690,28 @@ func uploadPhotos(recursive, force bool, maxFiles int64) error {
 			GoogleID: googleID,
 		})
 		if err != nil {
-			log.Printf("Failed to save upload record for %s: %v", path, err)
+			opts.fail(database, path, "Failed to save upload record", err)
 			return nil
 		}
 
-		log.Printf("Successfully uploaded %s", path)
-		uploadCount++
+		slog.Info("Uploaded", "path", path, "size", info.Size())
+		opts.stats.uploaded(info.Size())
+		uploadHook(path, info.Size(), hash, googleID)
+		if itemOpts.AlbumID != "" {
+			if err := database.SaveAlbumItem(itemOpts.AlbumID, googleID); err != nil {
+				slog.Error("Failed to save album item", "path", path, "err", err)
+			}
+		}
+		actions.add(source, file)
 
 		// Check if we've hit the limit after successful upload
-		if maxFiles > 0 && uploadCount >= maxFiles {
-			log.Printf("Reached upload limit of %d files", maxFiles)
+		if opts.maxFiles > 0 && opts.stats.FilesUploaded >= opts.maxFiles {
+			slog.Info("Reached upload limit", "max_files", opts.maxFiles)
 			return filepath.SkipAll
 		}
 		return nil
 	}
 
 	// Start walking the directory
-	return filepath.Walk(config.GetUploadPath(), walkFn)
+	return filepath.Walk(source.Path, walkFn)
 }
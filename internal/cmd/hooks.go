@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+
+	"github.com/navaneethkn/cronocam/internal/config"
+	"github.com/navaneethkn/cronocam/internal/db"
+	"github.com/navaneethkn/cronocam/internal/hooks"
+)
+
+// runHook runs the command configured for event, if any. Hook failures are
+// logged and never abort the run.
+func runHook(event string, payload any, env map[string]string) {
+	command := config.GetHookCommand(event)
+	if command == "" {
+		return
+	}
+	if profile := config.GetProfile(); profile != "" {
+		env["CRONOCAM_PROFILE"] = profile
+	}
+
+	output, err := hooks.Run(context.Background(), event, command, config.GetHookTimeout(), payload, env)
+	if err != nil {
+		slog.Warn("Hook failed", "event", event, "err", err)
+		return
+	}
+	slog.Debug("Hook finished", "event", event, "output", output)
+}
+
+// uploadHook reports an uploaded file to hooks.on_upload
+func uploadHook(path string, size int64, hash, googleID string) {
+	runHook(hooks.EventUpload, uploadEventOutput{
+		Event:    hooks.EventUpload,
+		Path:     path,
+		Size:     size,
+		Hash:     hash,
+		GoogleID: googleID,
+	}, map[string]string{
+		"CRONOCAM_PATH":      path,
+		"CRONOCAM_SIZE":      strconv.FormatInt(size, 10),
+		"CRONOCAM_HASH":      hash,
+		"CRONOCAM_GOOGLE_ID": googleID,
+	})
+}
+
+// failureHook reports a recorded upload error to hooks.on_failure
+func failureHook(uploadErr *db.UploadError) {
+	env := map[string]string{
+		"CRONOCAM_PATH":     uploadErr.File,
+		"CRONOCAM_ERROR":    uploadErr.Message,
+		"CRONOCAM_CATEGORY": uploadErr.Category,
+		"CRONOCAM_ATTEMPT":  strconv.Itoa(uploadErr.Attempt),
+	}
+	if uploadErr.NextRetryAt != nil {
+		env["CRONOCAM_NEXT_RETRY_AT"] = uploadErr.NextRetryAt.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	runHook(hooks.EventFailure, failureEventOutput{
+		Event:       hooks.EventFailure,
+		errorOutput: newErrorOutput(*uploadErr),
+	}, env)
+}
+
+// runCompleteHook reports a finished run to hooks.on_run_complete
+func runCompleteHook(run *db.Run) {
+	env := map[string]string{
+		"CRONOCAM_RUN_ID":         strconv.FormatInt(run.ID, 10),
+		"CRONOCAM_COMMAND":        run.Command,
+		"CRONOCAM_FILES_SCANNED":  strconv.FormatInt(run.FilesScanned, 10),
+		"CRONOCAM_FILES_SKIPPED":  strconv.FormatInt(run.FilesSkipped, 10),
+		"CRONOCAM_FILES_UPLOADED": strconv.FormatInt(run.FilesUploaded, 10),
+		"CRONOCAM_FILES_FAILED":   strconv.FormatInt(run.FilesFailed, 10),
+		"CRONOCAM_BYTES_SENT":     strconv.FormatInt(run.BytesSent, 10),
+		"CRONOCAM_DURATION":       strconv.FormatFloat(run.Duration().Seconds(), 'f', 0, 64),
+		"CRONOCAM_ERROR":          run.Error,
+	}
+	if run.ExitStatus != nil {
+		env["CRONOCAM_EXIT_STATUS"] = strconv.Itoa(*run.ExitStatus)
+	}
+	runHook(hooks.EventRunComplete, runCompleteEventOutput{
+		Event:     hooks.EventRunComplete,
+		runOutput: *newRunOutput(run),
+	}, env)
+}
@@ -2,16 +2,20 @@ package cmd
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 
 	"github.com/navaneethkn/cronocam/internal/config"
 	"github.com/navaneethkn/cronocam/internal/db"
+	"github.com/navaneethkn/cronocam/internal/pairing"
 	"github.com/navaneethkn/cronocam/internal/uploader"
 	"github.com/spf13/cobra"
 )
 
+// importBatchSize is the number of records committed per transaction
+const importBatchSize = 500
+
 var importCmd = &cobra.Command{
 	Use:   "import [directory]",
 	Short: "Import photos without uploading",
@@ -27,14 +31,20 @@ func init() {
 
 	// Add flags
 	importCmd.Flags().BoolP("recursive", "r", true, "recursively search for files in subdirectories")
+	addFilterFlags(importCmd)
+	addLockFlags(importCmd)
 }
 
-func runImport(cmd *cobra.Command, args []string) error {
+func runImport(cmd *cobra.Command, args []string) (err error) {
 	// Get directory path from args
 	dirPath := args[0]
 
 	// Get flags
 	recursive, _ := cmd.Flags().GetBool("recursive")
+	fileFilter, err := filterFromFlags(cmd)
+	if err != nil {
+		return err
+	}
 
 	// Convert to absolute path
 	absPath, err := filepath.Abs(dirPath)
@@ -63,6 +73,29 @@ func runImport(cmd *cobra.Command, args []string) error {
 	}
 	defer database.Close()
 
+	// Record this run in the run history, including lock failures
+	run := startRun(database, cmd, args)
+	defer func() { finishRun(database, run, err) }()
+
+	// Make sure no other run is working on the same database
+	runLock, err := acquireRunLock(cmd)
+	if err != nil {
+		return err
+	}
+	defer releaseRunLock(runLock)
+
+	stopMetrics, err := serveMetrics()
+	if err != nil {
+		return fmt.Errorf("failed to serve metrics: %v", err)
+	}
+	defer stopMetrics()
+
+	// Commit import records in batches. Nothing is sent to Google Photos, so
+	// a crash only loses records that the next import recreates.
+	if err := database.StartBatch(importBatchSize); err != nil {
+		return fmt.Errorf("failed to start batch: %v", err)
+	}
+
 	// Create uploader just for file type validation and hash calculation
 	// We don't need rate limiting for import, but we need valid config values
 	u, err := uploader.New(nil, uploader.Config{
@@ -75,6 +108,11 @@ func runImport(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to create uploader: %v", err)
 	}
 
+	counts := runCounts{RunStats: &run.RunStats}
+
+	// Detect paired files while scanning
+	pairs := pairing.NewDetector(config.GetSupportedRaw())
+
 	// Walk function for processing files
 	walkFn := func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -92,23 +130,42 @@ func runImport(cmd *cobra.Command, args []string) error {
 		if !u.IsSupportedFile(path) {
 			return nil
 		}
+		counts.scanned(path)
+
+		// Apply filters before hashing
+		file, reason := inspectFile(fileFilter, nil, path, info)
+		if file == nil {
+			slog.Info("Skipping file", "path", path, "reason", reason)
+			counts.skipped()
+			return nil
+		}
+
+		// Record Live Photo and RAW+JPEG pairs
+		detectPair(pairs, file)
+		savePair(database, file)
 
 		// Calculate file hash
 		hash, err := u.CalculateFileHash(path)
 		if err != nil {
-			log.Printf("Failed to calculate hash for %s: %v", path, err)
+			slog.Error("Failed to calculate hash", "path", path, "err", err)
+			counts.failed()
 			return nil
 		}
 
+		// Record capture metadata
+		saveFileMetadata(database, path, hash, file.meta)
+
 		// Check if file was already imported
 		imported, err := database.IsFileUploaded(hash)
 		if err != nil {
-			log.Printf("Failed to check import status for %s: %v", path, err)
+			slog.Error("Failed to check import status", "path", path, "err", err)
+			counts.failed()
 			return nil
 		}
 
 		if imported {
-			log.Printf("Skipping %s (already imported)", path)
+			slog.Info("Skipping file", "path", path, "reason", "already imported")
+			counts.skipped()
 			return nil
 		}
 
@@ -119,14 +176,23 @@ func runImport(cmd *cobra.Command, args []string) error {
 			GoogleID: "", // Empty since we're not uploading
 		})
 		if err != nil {
-			log.Printf("Failed to save import record for %s: %v", path, err)
+			slog.Error("Failed to save import record", "path", path, "err", err)
+			counts.failed()
 			return nil
 		}
 
-		log.Printf("Successfully imported %s", path)
+		slog.Info("Imported", "path", path)
+		counts.imported()
 		return nil
 	}
 
 	// Start walking the directory
-	return filepath.Walk(absPath, walkFn)
+	if err := filepath.Walk(absPath, walkFn); err != nil {
+		return err
+	}
+
+	if err := database.EndBatch(); err != nil {
+		return fmt.Errorf("failed to commit import records: %v", err)
+	}
+	return nil
 }
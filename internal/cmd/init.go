@@ -54,6 +54,15 @@ func runInit(cmd *cobra.Command, args []string) error {
 		},
 		"supported_images": config.DefaultSupportedImages,
 		"supported_videos": config.DefaultSupportedVideos,
+		"supported_raw":    config.DefaultSupportedRaw,
+		"description_template": config.DefaultDescriptionTemplate,
+		"live_photos": map[string]interface{}{
+			"video": config.DefaultLivePhotoVideo,
+			"album": config.DefaultLivePhotoAlbum,
+		},
+		"raw": map[string]interface{}{
+			"policy": config.DefaultRawPolicy,
+		},
 	}
 
 	// Validate config by attempting to marshal to YAML
@@ -92,6 +101,29 @@ func runInit(cmd *cobra.Command, args []string) error {
 %s: %s
 # Videos
 %s: %s
+# Camera RAW formats
+%s: %s
+
+# Go text/template for the description of uploaded media items.
+# Available fields: .Path, .RelativePath, .Folder, .Filename, .TakenAt,
+# .CameraMake, .CameraModel and .Hostname, e.g.
+#   "{{.Folder}} - {{.TakenAt.Format \"2006-01-02\"}}"
+# Leave empty to upload without a description.
+%s: %q
+
+# Live Photos (IMG_1234.HEIC + IMG_1234.MOV) are detected and tracked as pairs
+%s:
+  # What to do with the video half: upload, skip, or album (upload both
+  # halves into the album below)
+  %s: %s
+  %s: %q
+
+# RAW+JPEG pairs (IMG_1234.CR2 + IMG_1234.JPG)
+%s:
+  # Which files to upload: jpeg (JPEGs only, never RAW), raw (the RAW file of
+  # a pair instead of its JPEG), both, or raw_if_no_jpeg (RAW files only when
+  # there is no JPEG)
+  %s: %s
 `,
 		"credentials_path", defaultConfig["credentials_path"],
 		"database_path", defaultConfig["database_path"],
@@ -102,6 +134,13 @@ func runInit(cmd *cobra.Command, args []string) error {
 		"max_burst", defaultConfig["rate_limit"].(map[string]interface{})["max_burst"],
 		"supported_images", defaultConfig["supported_images"],
 		"supported_videos", defaultConfig["supported_videos"],
+		"supported_raw", defaultConfig["supported_raw"],
+		"description_template", defaultConfig["description_template"],
+		"live_photos",
+		"video", defaultConfig["live_photos"].(map[string]interface{})["video"],
+		"album", defaultConfig["live_photos"].(map[string]interface{})["album"],
+		"raw",
+		"policy", defaultConfig["raw"].(map[string]interface{})["policy"],
 	)
 
 	// Write to file
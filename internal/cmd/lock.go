@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/navaneethkn/cronocam/internal/config"
+	"github.com/navaneethkn/cronocam/internal/lock"
+	"github.com/spf13/cobra"
+)
+
+// lockPollInterval is how often --wait checks whether the lock was released
+const lockPollInterval = 5 * time.Second
+
+// addLockFlags registers the flags controlling the run lock
+func addLockFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("wait", false, "wait for a running upload or import to finish instead of exiting")
+}
+
+// acquireRunLock takes the exclusive lock that keeps runs from overlapping.
+// With --wait it blocks until the lock is free; otherwise it fails fast.
+func acquireRunLock(cmd *cobra.Command) (*lock.Lock, error) {
+	if err := config.EnsureDirectories(); err != nil {
+		return nil, fmt.Errorf("failed to create directories: %v", err)
+	}
+
+	wait, _ := cmd.Flags().GetBool("wait")
+	if !wait {
+		return lock.Acquire(config.GetLockPath(), cmd.Name())
+	}
+
+	return lock.Wait(context.Background(), config.GetLockPath(), cmd.Name(), lockPollInterval, func(holder *lock.Info) {
+		slog.Info("Waiting for the other run to finish", "holder", holder)
+	})
+}
+
+// releaseRunLock releases the run lock, logging any failure
+func releaseRunLock(l *lock.Lock) {
+	if err := l.Release(); err != nil {
+		slog.Error("Failed to release lock", "err", err)
+	}
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/navaneethkn/cronocam/internal/config"
+	"github.com/navaneethkn/cronocam/internal/db"
+	"github.com/navaneethkn/cronocam/internal/metrics"
+)
+
+// runCounts counts files into the run history, the exported metrics and
+// the progress display. Every scanned file ends up skipped, failed or
+// uploaded.
+type runCounts struct {
+	*db.RunStats
+	progress *progress
+}
+
+func (c runCounts) scanned(path string) {
+	c.FilesScanned++
+	metrics.FilesScanned.Inc()
+	c.progress.startFile(path)
+}
+
+func (c runCounts) skipped() {
+	c.FilesSkipped++
+	metrics.FilesSkipped.Inc()
+	c.progress.finishFile()
+}
+
+func (c runCounts) failed() {
+	c.FilesFailed++
+	metrics.FilesFailed.Inc()
+	c.progress.finishFile()
+}
+
+func (c runCounts) uploaded(size int64) {
+	c.FilesUploaded++
+	c.BytesSent += size
+	metrics.FilesUploaded.Inc()
+	metrics.BytesUploaded.Add(float64(size))
+	c.progress.finishFile()
+}
+
+// imported counts a file recorded by import, which sends nothing
+func (c runCounts) imported() {
+	c.FilesUploaded++
+	c.progress.finishFile()
+}
+
+// serveMetrics starts the metrics listener when metrics.listen is set. The
+// returned function stops it.
+func serveMetrics() (func(), error) {
+	addr := config.GetMetricsListen()
+	if addr == "" {
+		return func() {}, nil
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Default.Handler())
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Metrics server failed", "err", err)
+		}
+	}()
+	slog.Info("Serving metrics", "url", fmt.Sprintf("http://%s/metrics", listener.Addr()))
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}, nil
+}
+
+// recordRunMetrics updates the run gauges after a run and writes the
+// textfile-collector file when metrics.textfile is set
+func recordRunMetrics(database *db.DB, runErr error) {
+	metrics.LastRunTimestamp.Set(float64(time.Now().Unix()))
+	if runErr != nil {
+		metrics.LastRunSuccess.Set(0)
+	} else {
+		metrics.LastRunSuccess.Set(1)
+	}
+
+	pending, err := database.GetPendingCounts(time.Now())
+	if err != nil {
+		slog.Error("Failed to count pending files", "err", err)
+	} else {
+		metrics.PendingFiles.Set(float64(pending.Imported), "imported")
+		metrics.PendingFiles.Set(float64(pending.RetryDue), "retry_due")
+		metrics.PendingFiles.Set(float64(pending.RetryScheduled), "retry_scheduled")
+		metrics.PendingFiles.Set(float64(pending.FailedPermanently), "failed_permanently")
+	}
+
+	if path := config.GetMetricsTextfile(); path != "" {
+		if err := metrics.Default.WriteTextfile(path); err != nil {
+			slog.Error("Failed to write metrics", "path", path, "err", err)
+		}
+	}
+}
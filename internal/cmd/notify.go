@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/navaneethkn/cronocam/internal/config"
+	"github.com/navaneethkn/cronocam/internal/db"
+	"github.com/navaneethkn/cronocam/internal/notify"
+)
+
+// summaryErrorLimit caps the errors listed in a run summary
+const summaryErrorLimit = 20
+
+// notifyRun posts the summary of a finished run to the configured webhooks.
+// Webhooks set to "failure" are only notified when the run failed or had at
+// least their min_failures failed files.
+func notifyRun(database *db.DB, run *db.Run) {
+	webhooks, err := config.GetWebhooks()
+	if err != nil {
+		slog.Error("Failed to read webhooks", "err", err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	summary := newRunSummary(database, run)
+	notifier := notify.New(&http.Client{Timeout: config.GetNotifyTimeout()},
+		config.GetNotifyRetries(), config.GetNotifyRetryDelay())
+
+	for _, w := range webhooks {
+		if w.When == config.NotifyFailure && !summary.Failed() && summary.FilesFailed < int64(w.MinFailures) {
+			continue
+		}
+
+		webhook, err := notify.NewWebhook(w.Name, w.URL, w.Template, w.ContentType, w.Headers)
+		if err != nil {
+			slog.Error("Failed to send webhook", "webhook", w.Name, "err", err)
+			continue
+		}
+		if err := notifier.Send(context.Background(), webhook, summary); err != nil {
+			slog.Error("Failed to send webhook", "webhook", w.Name, "err", err)
+		}
+	}
+}
+
+// newRunSummary builds the webhook summary of a run, including the errors
+// recorded while it ran
+func newRunSummary(database *db.DB, run *db.Run) *notify.Summary {
+	hostname, _ := os.Hostname()
+	summary := &notify.Summary{
+		Hostname:        hostname,
+		Profile:         config.GetProfile(),
+		Command:         run.Command,
+		RunID:           run.ID,
+		Status:          "ok",
+		StartedAt:       run.StartedAt,
+		DurationSeconds: run.Duration().Seconds(),
+		FilesScanned:    run.FilesScanned,
+		FilesSkipped:    run.FilesSkipped,
+		FilesUploaded:   run.FilesUploaded,
+		FilesFailed:     run.FilesFailed,
+		BytesSent:       run.BytesSent,
+		Error:           run.Error,
+		Errors:          []string{},
+	}
+	if run.FinishedAt != nil {
+		summary.FinishedAt = *run.FinishedAt
+	} else {
+		summary.FinishedAt = time.Now().UTC()
+	}
+	if run.ExitStatus != nil && *run.ExitStatus != 0 {
+		summary.Status = "failed"
+	}
+
+	since := run.StartedAt
+	errors, err := database.GetErrors(db.ErrorFilter{Since: &since, Limit: summaryErrorLimit})
+	if err != nil {
+		slog.Error("Failed to get errors for run summary", "err", err)
+	}
+	for _, e := range errors {
+		summary.Errors = append(summary.Errors, fmt.Sprintf("%s: %s", e.File, e.Message))
+	}
+	return summary
+}
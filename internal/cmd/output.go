@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats accepted by --output
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// addOutputFlag registers --output on a command
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("output", "o", outputTable, "output format: table, json or yaml")
+}
+
+// outputFormat returns the validated --output format of a command
+func outputFormat(cmd *cobra.Command) (string, error) {
+	format, _ := cmd.Flags().GetString("output")
+	switch format {
+	case outputTable, outputJSON, outputYAML:
+		return format, nil
+	}
+	return "", fmt.Errorf("invalid output format %q (expected table, json or yaml)", format)
+}
+
+// writeOutput prints v to stdout as JSON or YAML
+func writeOutput(format string, v any) error {
+	switch format {
+	case outputJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case outputYAML:
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	return fmt.Errorf("output format %q is not structured", format)
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"log/slog"
+
+	"github.com/navaneethkn/cronocam/internal/config"
+	"github.com/navaneethkn/cronocam/internal/db"
+	"github.com/navaneethkn/cronocam/internal/pairing"
+)
+
+// detectPair finds the Live Photo or RAW+JPEG pair of a scanned file
+func detectPair(detector *pairing.Detector, file *scannedFile) {
+	file.pair = detector.LivePhoto(file.path, func(path string) string {
+		if path == file.path {
+			return file.meta.ContentID
+		}
+		return readFileMetadata(path).ContentID
+	})
+	if file.pair == nil {
+		file.pair = detector.RawJPEG(file.path)
+	}
+}
+
+// savePair records the pair of a scanned file in the database so both halves
+// are tracked as one photo
+func savePair(database *db.DB, file *scannedFile) {
+	if file.pair == nil {
+		return
+	}
+
+	err := database.SaveFilePair(&db.FilePair{
+		Kind:          string(file.pair.Kind),
+		PrimaryPath:   file.pair.Primary,
+		CompanionPath: file.pair.Companion,
+		ContentID:     file.pair.ContentID,
+	})
+	if err != nil {
+		slog.Error("Failed to save pair record", "path", file.path, "err", err)
+	}
+}
+
+// skipByPairPolicy returns the reason the configured Live Photo and RAW
+// policies leave file out of the upload, or an empty string
+func skipByPairPolicy(detector *pairing.Detector, file *scannedFile, opts uploadOptions) string {
+	pair := file.pair
+	reason := ""
+
+	switch {
+	case pair != nil && pair.Kind == pairing.LivePhoto:
+		if opts.livePhotoVideo == config.LivePhotoVideoSkip && pair.IsCompanion(file.path) {
+			reason = "Live Photo video of " + pair.Primary
+		}
+	case detector.IsRaw(file.path):
+		switch opts.rawPolicy {
+		case config.RawPolicyJPEG:
+			reason = "RAW files are not uploaded"
+		case config.RawPolicyRAWIfNoJPEG:
+			if pair != nil {
+				reason = "RAW file of " + pair.Primary
+			}
+		}
+	case pair != nil && pair.Kind == pairing.RawJPEG:
+		if opts.rawPolicy == config.RawPolicyRAW {
+			reason = "JPEG of " + pair.Companion
+		}
+	}
+
+	return reason
+}
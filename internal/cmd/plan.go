@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dustin/go-humanize"
+)
+
+// uploadPlan collects what a dry run would do. A nil *uploadPlan ignores
+// everything so real uploads can report into it unconditionally.
+type uploadPlan struct {
+	planOutput
+	// hashes maps the content hash of planned files to their path
+	hashes map[string]string
+	// paths holds every file already in the plan, such as due retries
+	// that the directory walk reaches again
+	paths map[string]bool
+}
+
+func newUploadPlan() *uploadPlan {
+	return &uploadPlan{
+		planOutput: planOutput{
+			Upload:     []plannedFileOutput{},
+			Skipped:    []skippedFileOutput{},
+			Duplicates: []duplicateFileOutput{},
+			Failed:     []failedFileOutput{},
+		},
+		hashes: make(map[string]string),
+		paths:  make(map[string]bool),
+	}
+}
+
+// upload plans the upload of a file, unless a file with the same content is
+// already planned, in which case the real run would skip it as uploaded
+func (p *uploadPlan) upload(path, hash string, size int64) {
+	p.paths[path] = true
+	if first, ok := p.hashes[hash]; ok {
+		p.Duplicates = append(p.Duplicates, duplicateFileOutput{Path: path, DuplicateOf: first})
+		return
+	}
+	p.hashes[hash] = path
+	p.Upload = append(p.Upload, plannedFileOutput{Path: path, Size: size})
+	p.TotalFiles++
+	p.TotalBytes += size
+}
+
+func (p *uploadPlan) skip(path, reason string) {
+	if p == nil {
+		return
+	}
+	p.paths[path] = true
+	if reason == reasonAlreadyUploaded {
+		p.Duplicates = append(p.Duplicates, duplicateFileOutput{Path: path, AlreadyUploaded: true})
+		return
+	}
+	p.Skipped = append(p.Skipped, skippedFileOutput{Path: path, Reason: reason})
+}
+
+func (p *uploadPlan) fail(path, message string, err error) {
+	p.paths[path] = true
+	p.Failed = append(p.Failed, failedFileOutput{Path: path, Error: fmt.Sprintf("%s: %v", message, err)})
+}
+
+// has reports whether path is already in the plan
+func (p *uploadPlan) has(path string) bool {
+	return p != nil && p.paths[path]
+}
+
+// print writes the plan as text
+func (p *uploadPlan) print() {
+	fmt.Printf("Files to upload (%d, %s):\n", p.TotalFiles, humanize.Bytes(uint64(p.TotalBytes)))
+	for _, f := range p.Upload {
+		fmt.Printf("  %10s  %s\n", humanize.Bytes(uint64(f.Size)), f.Path)
+	}
+
+	if len(p.Duplicates) > 0 {
+		fmt.Printf("\nDuplicates (%d):\n", len(p.Duplicates))
+		for _, f := range p.Duplicates {
+			if f.AlreadyUploaded {
+				fmt.Printf("  %s (already uploaded)\n", f.Path)
+			} else {
+				fmt.Printf("  %s (same as %s)\n", f.Path, f.DuplicateOf)
+			}
+		}
+	}
+
+	if len(p.Skipped) > 0 {
+		fmt.Printf("\nSkipped (%d):\n", len(p.Skipped))
+		for _, f := range p.Skipped {
+			fmt.Printf("  %s (%s)\n", f.Path, f.Reason)
+		}
+	}
+
+	if len(p.Failed) > 0 {
+		fmt.Printf("\nFailed (%d):\n", len(p.Failed))
+		for _, f := range p.Failed {
+			fmt.Printf("  %s: %s\n", f.Path, f.Error)
+		}
+	}
+
+	fmt.Printf("\nDry run: %d file%s (%s) would be uploaded, %d duplicate%s, %d skipped, %d failed\n",
+		p.TotalFiles, pluralize(p.TotalFiles), humanize.Bytes(uint64(p.TotalBytes)),
+		len(p.Duplicates), pluralize(len(p.Duplicates)), len(p.Skipped), len(p.Failed))
+}
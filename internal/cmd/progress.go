@@ -0,0 +1,304 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/navaneethkn/cronocam/internal/logging"
+)
+
+const (
+	// progressRedraw is how often the status line is redrawn on a terminal
+	progressRedraw = 500 * time.Millisecond
+	// progressLogInterval is how often a summary is logged otherwise
+	progressLogInterval = time.Minute
+	// throughputWindow is the period the current throughput is measured over
+	throughputWindow = 10 * time.Second
+)
+
+// progress follows an upload run. On a terminal it keeps a status line with
+// the overall files and bytes, throughput, ETA and the progress of the file
+// being uploaded below the log. Elsewhere it logs a summary periodically.
+//
+// A nil *progress is valid and does nothing, so imports and runs without a
+// display can share the code paths of uploads.
+type progress struct {
+	mu  sync.Mutex
+	out io.Writer
+	tty bool
+
+	// Totals, known on a terminal where files are counted up front
+	totalFiles int64
+	totalBytes int64
+	sizes      map[string]int64
+
+	doneFiles int64
+	doneBytes int64
+	sentBytes int64
+
+	current     string
+	currentSize int64
+	currentSent int64
+
+	samples []progressSample
+	start   time.Time
+
+	stop    chan struct{}
+	stopped chan struct{}
+	restore func()
+}
+
+type progressSample struct {
+	time time.Time
+	sent int64
+}
+
+// isTerminal reports whether f is attached to a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// newProgress starts following a run. The status line is drawn only when
+// stdout is a terminal.
+func newProgress() *progress {
+	p := &progress{
+		out:     os.Stdout,
+		tty:     isTerminal(os.Stdout),
+		sizes:   make(map[string]int64),
+		start:   time.Now(),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if p.tty {
+		p.restore = logging.RedirectConsole(p)
+	}
+	go p.run()
+	return p
+}
+
+func (p *progress) run() {
+	defer close(p.stopped)
+
+	interval := progressLogInterval
+	if p.tty {
+		interval = progressRedraw
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.mu.Lock()
+			p.sample()
+			if p.tty {
+				p.draw()
+			} else {
+				p.logSummary()
+			}
+			p.mu.Unlock()
+		}
+	}
+}
+
+// Close stops the display and leaves a final summary
+func (p *progress) Close() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	<-p.stopped
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tty {
+		p.restore()
+		fmt.Fprint(p.out, "\r\x1b[K")
+		fmt.Fprintf(p.out, "%d file%s processed, %s sent in %s\n", p.doneFiles, pluralize(int(p.doneFiles)),
+			humanize.Bytes(uint64(p.sentBytes)), time.Since(p.start).Round(time.Second))
+	}
+}
+
+// Write prints a log record above the status line
+func (p *progress) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprint(p.out, "\r\x1b[K")
+	n, err := os.Stderr.Write(b)
+	p.draw()
+	return n, err
+}
+
+// countFiles adds files to the totals. It only runs on a terminal, where
+// the totals are shown, to spare unattended runs the extra pass.
+func (p *progress) countFiles(paths []string) {
+	if p == nil || !p.tty {
+		return
+	}
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			p.addTotal(path, info.Size())
+		}
+	}
+}
+
+// countTree adds the supported files below root to the totals
+func (p *progress) countTree(root string, recursive bool, supported func(string) bool) {
+	if p == nil || !p.tty {
+		return
+	}
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if !recursive && path != root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if supported(path) {
+			p.addTotal(path, info.Size())
+		}
+		return nil
+	})
+}
+
+func (p *progress) addTotal(path string, size int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.sizes[path]; ok {
+		// Retried files can come up again while walking the directory
+		return
+	}
+	p.sizes[path] = size
+	p.totalFiles++
+	p.totalBytes += size
+}
+
+// startFile marks path as the file being processed
+func (p *progress) startFile(path string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current = path
+	p.currentSize = p.sizes[path]
+	p.currentSent = 0
+}
+
+// chunk records the bytes of the current file sent so far. It is called by
+// the uploader after each chunk.
+func (p *progress) chunk(sent, total int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sentBytes += sent - p.currentSent
+	p.currentSent = sent
+	p.currentSize = total
+	p.sample()
+	if p.tty {
+		p.draw()
+	}
+}
+
+// finishFile marks the current file as done, whatever its outcome
+func (p *progress) finishFile() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.doneFiles++
+	p.doneBytes += p.sizes[p.current]
+	p.current = ""
+	p.currentSize = 0
+	p.currentSent = 0
+}
+
+func (p *progress) sample() {
+	now := time.Now()
+	p.samples = append(p.samples, progressSample{time: now, sent: p.sentBytes})
+	for len(p.samples) > 2 && now.Sub(p.samples[1].time) > throughputWindow {
+		p.samples = p.samples[1:]
+	}
+}
+
+// throughput returns the bytes sent per second over the last few seconds
+func (p *progress) throughput() float64 {
+	if len(p.samples) < 2 {
+		return 0
+	}
+	first, last := p.samples[0], p.samples[len(p.samples)-1]
+	elapsed := last.time.Sub(first.time).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(last.sent-first.sent) / elapsed
+}
+
+// eta estimates the time left from the bytes not processed yet. Files that
+// turn out to be skipped make it shrink faster than it counts down.
+func (p *progress) eta() (time.Duration, bool) {
+	rate := p.throughput()
+	remaining := p.totalBytes - p.doneBytes - p.currentSent
+	if rate <= 0 || p.totalBytes == 0 || remaining < 0 {
+		return 0, false
+	}
+	return time.Duration(float64(remaining) / rate * float64(time.Second)), true
+}
+
+func (p *progress) draw() {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%d/%d files] %s/%s", p.doneFiles, p.totalFiles,
+		humanize.Bytes(uint64(p.doneBytes+p.currentSent)), humanize.Bytes(uint64(p.totalBytes)))
+	fmt.Fprintf(&b, "  %s/s", humanize.Bytes(uint64(p.throughput())))
+	if eta, ok := p.eta(); ok {
+		fmt.Fprintf(&b, "  ETA %s", eta.Round(time.Second))
+	}
+	if p.current != "" {
+		fmt.Fprintf(&b, "  %s", truncateName(filepath.Base(p.current), 32))
+		if p.currentSize > 0 && p.currentSent > 0 {
+			fmt.Fprintf(&b, " %d%% of %s", p.currentSent*100/p.currentSize, humanize.Bytes(uint64(p.currentSize)))
+		}
+	}
+	fmt.Fprintf(p.out, "\r\x1b[K%s", b.String())
+}
+
+func (p *progress) logSummary() {
+	args := []any{
+		"files_done", p.doneFiles,
+		"bytes_sent", p.sentBytes,
+		"throughput", humanize.Bytes(uint64(p.throughput())) + "/s",
+		"elapsed", time.Since(p.start).Round(time.Second),
+	}
+	if p.current != "" {
+		args = append(args, "current", p.current)
+		if p.currentSize > 0 {
+			args = append(args, "current_percent", p.currentSent*100/p.currentSize)
+		}
+	}
+	slog.Info("Progress", args...)
+}
+
+// truncateName shortens name to at most n characters, keeping its end
+func truncateName(name string, n int) string {
+	r := []rune(name)
+	if len(r) <= n {
+		return name
+	}
+	return "…" + string(r[len(r)-n+1:])
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/navaneethkn/cronocam/internal/config"
+	"github.com/navaneethkn/cronocam/internal/db"
+	"github.com/navaneethkn/cronocam/internal/metrics"
+	"github.com/navaneethkn/cronocam/internal/uploader"
+)
+
+// saveUploadError classifies a failed upload step, schedules the next
+// automatic retry of transient failures and stores the error, which is
+// returned
+func saveUploadError(database *db.DB, path, message string, err error) *db.UploadError {
+	category := uploader.Classify(err)
+	metrics.UploadErrors.Inc(string(category))
+
+	attempt := 1
+	last, lastErr := database.GetLastError(path)
+	if lastErr != nil {
+		slog.Error("Failed to read error history", "path", path, "err", lastErr)
+	} else if last != nil {
+		attempt = last.Attempt + 1
+	}
+
+	uploadErr := &db.UploadError{
+		File:     path,
+		Message:  fmt.Sprintf("%s: %v", message, err),
+		Time:     time.Now().UTC(),
+		Category: string(category),
+		Attempt:  attempt,
+	}
+	if category.Transient() && attempt < config.GetRetryMaxAttempts() {
+		nextRetryAt := time.Now().Add(retryDelay(attempt))
+		uploadErr.NextRetryAt = &nextRetryAt
+	}
+
+	if err := database.SaveUploadError(uploadErr); err != nil {
+		slog.Error("Failed to save error record", "path", path, "err", err)
+	}
+	return uploadErr
+}
+
+// retryDelay returns the backoff after the given number of failed attempts
+func retryDelay(attempt int) time.Duration {
+	delay := config.GetRetryInitialDelay()
+	maxDelay := config.GetRetryMaxDelay()
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	return delay
+}
+
+// skipByRetrySchedule returns the reason a previously failed file is left
+// out of this run, either because its failure is permanent or because its
+// next retry is not due yet, or an empty string. Forced runs and
+// --retry-failed try every file.
+func skipByRetrySchedule(database *db.DB, path string, opts uploadOptions) string {
+	if opts.force || opts.retryFailed {
+		return ""
+	}
+
+	last, err := database.GetLastError(path)
+	if err != nil {
+		slog.Error("Failed to read error history", "path", path, "err", err)
+		return ""
+	}
+
+	if last == nil {
+		return ""
+	}
+
+	ignored, err := database.IsFileIgnored(path)
+	if err != nil {
+		slog.Error("Failed to read error history", "path", path, "err", err)
+		return ""
+	}
+
+	switch {
+	case ignored:
+		return "errors ignored"
+	case last.Permanent():
+		return fmt.Sprintf("failed permanently with a %s error, use --retry-failed to retry", last.Category)
+	case last.NextRetryAt != nil && time.Now().Before(*last.NextRetryAt):
+		return "retry scheduled for " + last.NextRetryAt.Local().Format("2006-01-02 15:04:05")
+	}
+	return ""
+}
+
+// retryDueFiles uploads the files whose transient failures are due for
+// another attempt. Failures are recorded and rescheduled, not returned, and
+// the files are marked so the rest of the run leaves them alone.
+func retryDueFiles(database *db.DB, opts uploadOptions) error {
+	files, err := database.GetDueRetries(time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to get files due for retry: %v", err)
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	slog.Info("Retrying previously failed files", "count", len(files))
+	if err := uploadFiles(database, files, opts); err != nil {
+		slog.Error("Retry of failed files incomplete", "err", err)
+	}
+	for _, path := range files {
+		opts.retried[path] = true
+	}
+	return nil
+}
@@ -2,10 +2,13 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 
-	"github.com/spf13/cobra"
 	"github.com/navaneethkn/cronocam/internal/config"
+	"github.com/navaneethkn/cronocam/internal/logging"
+	"github.com/spf13/cobra"
 )
 
 // Version information (set by build)
@@ -13,27 +16,46 @@ var Version = "dev"
 
 var (
 	cfgFile string
+	logFile io.Closer
 	rootCmd = &cobra.Command{
 		Use:     "cronocam",
 		Version: Version,
 		Short:   "A tool for uploading photos to Google Photos",
 		Long: `cronocam is a command-line tool for uploading photos to Google Photos.
 It supports batch uploading, resumable uploads, and tracks uploaded files
-to avoid duplicates.`,
+to avoid duplicates.
+
+Logs go to stderr, or to the file set with --log-file, so stdout only holds
+the output of commands.
+
+Several Google accounts can be used from one machine through the profiles
+of the config file. Select one with --profile; each has its own token,
+database and sources.`,
+		SilenceErrors: true,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			// Initialize configuration before any command runs
 			if err := config.Initialize(cfgFile); err != nil {
 				return fmt.Errorf("failed to initialize config: %v", err)
 			}
-			return nil
+			profile, _ := cmd.Flags().GetString("profile")
+			if err := config.SetProfile(profile); err != nil {
+				return err
+			}
+			return setupLogging(cmd)
 		},
 	}
 )
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+	err := rootCmd.Execute()
+	if err != nil {
+		slog.Error(err.Error())
+	}
+	if logFile != nil {
+		logFile.Close()
+	}
+	if err != nil {
 		os.Exit(1)
 	}
 }
@@ -42,8 +64,47 @@ func init() {
 	cobra.OnInitialize(initConfig)
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is ./config.yaml)")
+	rootCmd.PersistentFlags().String("profile", "", "use the token, database and sources of this profile (default $PHOTOS_PROFILE)")
+	rootCmd.PersistentFlags().String("log-level", config.DefaultLogLevel, "minimum level of log messages: debug, info, warn or error")
+	rootCmd.PersistentFlags().String("log-format", config.DefaultLogFormat, "log format: text or json")
+	rootCmd.PersistentFlags().String("log-file", "", "write logs to this file instead of stderr, rotating it by size")
 }
 
 func initConfig() {
 	// Config initialization is handled in config.Initialize
 }
+
+// setupLogging installs the default logger. Flags override the log.*
+// settings of the config file.
+func setupLogging(cmd *cobra.Command) error {
+	opts := logging.Options{
+		Level:      config.GetLogLevel(),
+		Format:     config.GetLogFormat(),
+		File:       config.GetLogFile(),
+		MaxSize:    config.GetLogMaxSize(),
+		MaxBackups: config.GetLogMaxBackups(),
+	}
+	flags := cmd.Flags()
+	if flags.Changed("log-level") {
+		opts.Level, _ = flags.GetString("log-level")
+	}
+	if flags.Changed("log-format") {
+		opts.Format, _ = flags.GetString("log-format")
+	}
+	if flags.Changed("log-file") {
+		opts.File, _ = flags.GetString("log-file")
+	}
+
+	closer, err := logging.Setup(opts)
+	if err != nil {
+		return err
+	}
+	logFile = closer
+
+	if path := config.FileUsed(); path != "" {
+		slog.Debug("Using config file", "path", path)
+	} else {
+		slog.Debug("No config file found, using default values")
+	}
+	return nil
+}
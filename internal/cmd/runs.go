@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/navaneethkn/cronocam/internal/config"
+	"github.com/navaneethkn/cronocam/internal/db"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var runsCmd = &cobra.Command{
+	Use:   "runs",
+	Short: "Show the history of upload and import runs",
+	Long: `List recent upload and import runs with their file counts and
+exit status. Use "runs show <id>" for the details of a single run.`,
+	Args: cobra.NoArgs,
+	RunE: runRuns,
+}
+
+var runsShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show the details of a run",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRunsShow,
+}
+
+func init() {
+	rootCmd.AddCommand(runsCmd)
+	runsCmd.AddCommand(runsShowCmd)
+
+	runsCmd.Flags().IntP("limit", "n", 20, "number of runs to show")
+	addOutputFlag(runsCmd)
+	addOutputFlag(runsShowCmd)
+}
+
+// startRun records the start of a command in the run history. A run is
+// returned even if it could not be saved so callers can always count into
+// its statistics.
+func startRun(database *db.DB, cmd *cobra.Command, args []string) *db.Run {
+	run, err := database.StartRun(cmd.Name(), runArguments(cmd, args))
+	if err != nil {
+		slog.Error("Failed to record run", "err", err)
+		return &db.Run{Command: cmd.Name()}
+	}
+	return run
+}
+
+// finishRun records the outcome of a command started with startRun, updates
+// the run metrics and the heartbeat, runs the run-complete hook and notifies
+// webhooks
+func finishRun(database *db.DB, run *db.Run, runErr error) {
+	recordRunMetrics(database, runErr)
+
+	status := 0
+	if runErr != nil {
+		status = 1
+		run.Error = runErr.Error()
+	}
+	run.ExitStatus = &status
+	if run.ID != 0 {
+		if err := database.FinishRun(run); err != nil {
+			slog.Error("Failed to record run", "err", err)
+		}
+	}
+	writeHeartbeat(database, run)
+	runCompleteHook(run)
+	notifyRun(database, run)
+}
+
+// runArguments rebuilds the command line of a run from its arguments and
+// the flags that were set explicitly
+func runArguments(cmd *cobra.Command, args []string) string {
+	var parts []string
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		parts = append(parts, fmt.Sprintf("--%s=%s", f.Name, f.Value))
+	})
+	parts = append(parts, args...)
+	return strings.Join(parts, " ")
+}
+
+func runRuns(cmd *cobra.Command, args []string) error {
+	limit, _ := cmd.Flags().GetInt("limit")
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	database, err := db.New(config.GetDatabasePath())
+	if err != nil {
+		return fmt.Errorf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	runs, err := database.GetRuns(limit)
+	if err != nil {
+		return fmt.Errorf("failed to get runs: %v", err)
+	}
+
+	if format != outputTable {
+		out := make([]*runOutput, 0, len(runs))
+		for i := range runs {
+			out = append(out, newRunOutput(&runs[i]))
+		}
+		return writeOutput(format, out)
+	}
+
+	if len(runs) == 0 {
+		fmt.Println("No runs recorded yet")
+		return nil
+	}
+
+	fmt.Printf("%-6s %-8s %-20s %-10s %8s %8s %8s %8s %10s  %s\n",
+		"ID", "COMMAND", "STARTED", "DURATION", "SCANNED", "SKIPPED", "UPLOADED", "FAILED", "SENT", "STATUS")
+	for _, run := range runs {
+		fmt.Printf("%-6d %-8s %-20s %-10s %8d %8d %8d %8d %10s  %s\n",
+			run.ID, run.Command, run.StartedAt.Local().Format("2006-01-02 15:04:05"),
+			formatRunDuration(&run), run.FilesScanned, run.FilesSkipped, run.FilesUploaded,
+			run.FilesFailed, humanize.Bytes(uint64(run.BytesSent)), formatRunStatus(&run))
+	}
+	return nil
+}
+
+func runRunsShow(cmd *cobra.Command, args []string) error {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid run ID %q", args[0])
+	}
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	database, err := db.New(config.GetDatabasePath())
+	if err != nil {
+		return fmt.Errorf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	run, err := database.GetRun(id)
+	if err != nil {
+		return fmt.Errorf("failed to get run: %v", err)
+	}
+	if run == nil {
+		return fmt.Errorf("run %d not found", id)
+	}
+
+	if format != outputTable {
+		return writeOutput(format, newRunOutput(run))
+	}
+
+	fmt.Printf("Run %d:\n", run.ID)
+	fmt.Printf("-------------\n")
+	fmt.Printf("Command: %s\n", strings.TrimSpace(run.Command+" "+run.Arguments))
+	fmt.Printf("Started: %s\n", run.StartedAt.Local().Format(time.RFC1123))
+	if run.FinishedAt != nil {
+		fmt.Printf("Finished: %s\n", run.FinishedAt.Local().Format(time.RFC1123))
+	} else {
+		fmt.Printf("Finished: Never\n")
+	}
+	fmt.Printf("Duration: %s\n", formatRunDuration(run))
+	fmt.Printf("Files scanned: %d\n", run.FilesScanned)
+	fmt.Printf("Files skipped: %d\n", run.FilesSkipped)
+	fmt.Printf("Files uploaded: %d\n", run.FilesUploaded)
+	fmt.Printf("Files failed: %d\n", run.FilesFailed)
+	fmt.Printf("Bytes sent: %s (%d bytes)\n", humanize.Bytes(uint64(run.BytesSent)), run.BytesSent)
+	fmt.Printf("Status: %s\n", formatRunStatus(run))
+	if run.Error != "" {
+		fmt.Printf("Error: %s\n", run.Error)
+	}
+	return nil
+}
+
+func formatRunDuration(run *db.Run) string {
+	if run.FinishedAt == nil {
+		return "-"
+	}
+	return run.Duration().String()
+}
+
+// formatRunStatus describes the exit status of a run. Runs that never
+// finished were either still going or were killed before recording it.
+func formatRunStatus(run *db.Run) string {
+	switch {
+	case run.ExitStatus == nil:
+		return "unfinished"
+	case *run.ExitStatus == 0:
+		return "ok"
+	default:
+		return fmt.Sprintf("failed (exit %d)", *run.ExitStatus)
+	}
+}
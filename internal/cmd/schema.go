@@ -0,0 +1,251 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/navaneethkn/cronocam/internal/db"
+)
+
+// The types below form the schema of "--output json" and "--output yaml".
+// Scripts depend on them: fields may be added, but existing fields are never
+// renamed, removed or given a different meaning. Bump statusSchemaVersion if
+// that ever has to change.
+
+// statusSchemaVersion is reported in the status output
+const statusSchemaVersion = 1
+
+// statusOutput is the output of "status"
+type statusOutput struct {
+	SchemaVersion int              `json:"schema_version" yaml:"schema_version"`
+	Profile       string           `json:"profile" yaml:"profile"`
+	Paths         pathsOutput      `json:"paths" yaml:"paths"`
+	Stats         statsOutput      `json:"stats" yaml:"stats"`
+	Pending       pendingOutput    `json:"pending" yaml:"pending"`
+	Pairs         []pairOutput     `json:"pairs" yaml:"pairs"`
+	RecentErrors  []errorOutput    `json:"recent_errors" yaml:"recent_errors"`
+	LastRun       *runOutput       `json:"last_run" yaml:"last_run"`
+	ActiveRun     *activeRunOutput `json:"active_run" yaml:"active_run"`
+	Auth          authOutput       `json:"auth" yaml:"auth"`
+}
+
+type pathsOutput struct {
+	Credentials string `json:"credentials" yaml:"credentials"`
+	Token       string `json:"token" yaml:"token"`
+	Database    string `json:"database" yaml:"database"`
+}
+
+// statsOutput mirrors db.UploadStats
+type statsOutput struct {
+	TotalUploaded  int64      `json:"total_uploaded" yaml:"total_uploaded"`
+	TotalErrors    int64      `json:"total_errors" yaml:"total_errors"`
+	LastUploadTime *time.Time `json:"last_upload_time" yaml:"last_upload_time"`
+}
+
+// pendingOutput mirrors db.PendingCounts
+type pendingOutput struct {
+	Imported          int64 `json:"imported" yaml:"imported"`
+	RetryDue          int64 `json:"retry_due" yaml:"retry_due"`
+	RetryScheduled    int64 `json:"retry_scheduled" yaml:"retry_scheduled"`
+	FailedPermanently int64 `json:"failed_permanently" yaml:"failed_permanently"`
+}
+
+type pairOutput struct {
+	Kind              string `json:"kind" yaml:"kind"`
+	Total             int64  `json:"total" yaml:"total"`
+	PrimaryUploaded   int64  `json:"primary_uploaded" yaml:"primary_uploaded"`
+	CompanionUploaded int64  `json:"companion_uploaded" yaml:"companion_uploaded"`
+}
+
+// activeRunOutput describes the holder of the run lock
+type activeRunOutput struct {
+	Command   string    `json:"command" yaml:"command"`
+	PID       int       `json:"pid" yaml:"pid"`
+	Host      string    `json:"host" yaml:"host"`
+	StartedAt time.Time `json:"started_at" yaml:"started_at"`
+	Stale     bool      `json:"stale" yaml:"stale"`
+}
+
+// authOutput describes the stored OAuth token. State is one of
+// "no_credentials" or the auth.Token* states.
+type authOutput struct {
+	State           string     `json:"state" yaml:"state"`
+	TokenExpiry     *time.Time `json:"token_expiry" yaml:"token_expiry"`
+	HasRefreshToken bool       `json:"has_refresh_token" yaml:"has_refresh_token"`
+	Encrypted       bool       `json:"encrypted" yaml:"encrypted"`
+	Scopes          []string   `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+	MissingScopes   []string   `json:"missing_scopes,omitempty" yaml:"missing_scopes,omitempty"`
+}
+
+// authStatusOutput is the output of "auth status"
+type authStatusOutput struct {
+	Profile   string     `json:"profile" yaml:"profile"`
+	TokenPath string     `json:"token_path" yaml:"token_path"`
+	Token     authOutput `json:"token" yaml:"token"`
+	// Google is what Google reports about the token; nil with --offline or
+	// without a usable token
+	Google *authGoogleOutput `json:"google" yaml:"google"`
+}
+
+type authGoogleOutput struct {
+	// Refresh is "ok", "failed" or "no_refresh_token"
+	Refresh      string `json:"refresh" yaml:"refresh"`
+	RefreshError string `json:"refresh_error,omitempty" yaml:"refresh_error,omitempty"`
+	// Scopes are the scopes granted to the access token
+	Scopes []string `json:"scopes" yaml:"scopes"`
+	// Email is empty unless the token was granted an email scope
+	Email string `json:"email" yaml:"email"`
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// errorOutput is a recorded upload error
+type errorOutput struct {
+	Path        string     `json:"path" yaml:"path"`
+	Message     string     `json:"message" yaml:"message"`
+	Category    string     `json:"category,omitempty" yaml:"category,omitempty"`
+	Attempt     int        `json:"attempt" yaml:"attempt"`
+	Time        time.Time  `json:"time" yaml:"time"`
+	NextRetryAt *time.Time `json:"next_retry_at,omitempty" yaml:"next_retry_at,omitempty"`
+}
+
+// errorGroupOutput summarizes the errors sharing one message
+type errorGroupOutput struct {
+	Message   string    `json:"message" yaml:"message"`
+	Category  string    `json:"category,omitempty" yaml:"category,omitempty"`
+	Count     int       `json:"count" yaml:"count"`
+	FirstSeen time.Time `json:"first_seen" yaml:"first_seen"`
+	LastSeen  time.Time `json:"last_seen" yaml:"last_seen"`
+	Paths     []string  `json:"paths" yaml:"paths"`
+}
+
+// runOutput is an entry of the run history
+type runOutput struct {
+	ID              int64      `json:"id" yaml:"id"`
+	Command         string     `json:"command" yaml:"command"`
+	Arguments       string     `json:"arguments" yaml:"arguments"`
+	StartedAt       time.Time  `json:"started_at" yaml:"started_at"`
+	FinishedAt      *time.Time `json:"finished_at" yaml:"finished_at"`
+	DurationSeconds float64    `json:"duration_seconds" yaml:"duration_seconds"`
+	FilesScanned    int64      `json:"files_scanned" yaml:"files_scanned"`
+	FilesSkipped    int64      `json:"files_skipped" yaml:"files_skipped"`
+	FilesUploaded   int64      `json:"files_uploaded" yaml:"files_uploaded"`
+	FilesFailed     int64      `json:"files_failed" yaml:"files_failed"`
+	BytesSent       int64      `json:"bytes_sent" yaml:"bytes_sent"`
+	ExitStatus      *int       `json:"exit_status" yaml:"exit_status"`
+	Error           string     `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// heartbeatOutput is the heartbeat file written after every run and read by
+// "health"
+type heartbeatOutput struct {
+	SchemaVersion  int           `json:"schema_version" yaml:"schema_version"`
+	UpdatedAt      time.Time     `json:"updated_at" yaml:"updated_at"`
+	Hostname       string        `json:"hostname" yaml:"hostname"`
+	LastRun        *runOutput    `json:"last_run" yaml:"last_run"`
+	LastSuccessRun *runOutput    `json:"last_success_run" yaml:"last_success_run"`
+	Pending        pendingOutput `json:"pending" yaml:"pending"`
+	Auth           authOutput    `json:"auth" yaml:"auth"`
+}
+
+// healthOutput is the output of "health"
+type healthOutput struct {
+	Healthy bool                `json:"healthy" yaml:"healthy"`
+	Checks  []healthCheckOutput `json:"checks" yaml:"checks"`
+}
+
+type healthCheckOutput struct {
+	Name    string `json:"name" yaml:"name"`
+	OK      bool   `json:"ok" yaml:"ok"`
+	Message string `json:"message" yaml:"message"`
+}
+
+// planOutput is the output of "upload --dry-run"
+type planOutput struct {
+	Upload     []plannedFileOutput   `json:"upload" yaml:"upload"`
+	Skipped    []skippedFileOutput   `json:"skipped" yaml:"skipped"`
+	Duplicates []duplicateFileOutput `json:"duplicates" yaml:"duplicates"`
+	Failed     []failedFileOutput    `json:"failed" yaml:"failed"`
+	TotalFiles int                   `json:"total_files" yaml:"total_files"`
+	TotalBytes int64                 `json:"total_bytes" yaml:"total_bytes"`
+}
+
+// plannedFileOutput is a file a dry run would upload
+type plannedFileOutput struct {
+	Path string `json:"path" yaml:"path"`
+	Size int64  `json:"size" yaml:"size"`
+}
+
+type skippedFileOutput struct {
+	Path   string `json:"path" yaml:"path"`
+	Reason string `json:"reason" yaml:"reason"`
+}
+
+// duplicateFileOutput is a file whose content is already uploaded or is
+// planned under another path, given in DuplicateOf
+type duplicateFileOutput struct {
+	Path            string `json:"path" yaml:"path"`
+	AlreadyUploaded bool   `json:"already_uploaded" yaml:"already_uploaded"`
+	DuplicateOf     string `json:"duplicate_of,omitempty" yaml:"duplicate_of,omitempty"`
+}
+
+type failedFileOutput struct {
+	Path  string `json:"path" yaml:"path"`
+	Error string `json:"error" yaml:"error"`
+}
+
+// uploadEventOutput is the hooks.on_upload payload
+type uploadEventOutput struct {
+	Event    string `json:"event"`
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Hash     string `json:"hash"`
+	GoogleID string `json:"google_id"`
+}
+
+// failureEventOutput is the hooks.on_failure payload
+type failureEventOutput struct {
+	Event string `json:"event"`
+	errorOutput
+}
+
+// runCompleteEventOutput is the hooks.on_run_complete payload
+type runCompleteEventOutput struct {
+	Event string `json:"event"`
+	runOutput
+}
+
+func newErrorOutput(e db.UploadError) errorOutput {
+	return errorOutput{
+		Path:        e.File,
+		Message:     e.Message,
+		Category:    e.Category,
+		Attempt:     e.Attempt,
+		Time:        e.Time,
+		NextRetryAt: e.NextRetryAt,
+	}
+}
+
+func newErrorOutputs(errors []db.UploadError) []errorOutput {
+	out := make([]errorOutput, 0, len(errors))
+	for _, e := range errors {
+		out = append(out, newErrorOutput(e))
+	}
+	return out
+}
+
+func newRunOutput(run *db.Run) *runOutput {
+	return &runOutput{
+		ID:              run.ID,
+		Command:         run.Command,
+		Arguments:       run.Arguments,
+		StartedAt:       run.StartedAt,
+		FinishedAt:      run.FinishedAt,
+		DurationSeconds: run.Duration().Seconds(),
+		FilesScanned:    run.FilesScanned,
+		FilesSkipped:    run.FilesSkipped,
+		FilesUploaded:   run.FilesUploaded,
+		FilesFailed:     run.FilesFailed,
+		BytesSent:       run.BytesSent,
+		ExitStatus:      run.ExitStatus,
+		Error:           run.Error,
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/spf13/cobra"
+	"github.com/navaneethkn/cronocam/internal/auth"
 	"github.com/navaneethkn/cronocam/internal/config"
 )
 
@@ -11,12 +12,24 @@ var setupCmd = &cobra.Command{
 	Use:   "setup",
 	Short: "Setup Google Photos authentication",
 	Long: `Setup Google Photos authentication by performing OAuth2 flow.
-This will open your browser for authentication and save the credentials.`,
+This will open your browser for authentication and save the credentials.
+
+Only the access the enabled features need is requested: adding photos and
+videos, and managing the albums cronocam creates when a source has an album
+policy or live_photos.video is album. Use --albums to also authorize albums
+for upload --takeout. Setup explains each scope it requests, and authorizes
+again when the stored token lacks one.
+
+With --profile the account of that profile is authorized and its token is
+stored separately, so run setup once per profile and pick the matching
+Google account in the browser.`,
 	RunE: runSetup,
 }
 
 func init() {
 	rootCmd.AddCommand(setupCmd)
+
+	setupCmd.Flags().Bool("albums", false, "also authorize creating albums, as needed by upload --takeout")
 }
 
 func runSetup(cmd *cobra.Command, args []string) error {
@@ -25,10 +38,23 @@ func runSetup(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to create directories: %v", err)
 	}
 
-	if err := setupAuth(); err != nil {
+	profile := config.GetProfile()
+	if profile != "" {
+		fmt.Printf("Setting up profile %s\n", profile)
+	}
+
+	extra := make(map[string]string)
+	if albums, _ := cmd.Flags().GetBool("albums"); albums {
+		extra[auth.FeatureAlbums] = "--albums"
+	}
+	if err := setupAuth(extra); err != nil {
 		return err
 	}
 
+	if profile != "" {
+		fmt.Printf("Setup of profile %s completed successfully!\n", profile)
+		return nil
+	}
 	fmt.Println("Setup completed successfully!")
 	return nil
 }
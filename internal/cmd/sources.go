@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/navaneethkn/cronocam/internal/config"
+	"github.com/navaneethkn/cronocam/internal/pairing"
+)
+
+// sourceOf returns the configured source a file belongs to, preferring the
+// most specific one when sources are nested, or nil
+func sourceOf(sources []config.Source, path string) *config.Source {
+	var found *config.Source
+	for i := range sources {
+		if sources[i].Contains(path) && (found == nil || len(sources[i].Path) > len(found.Path)) {
+			found = &sources[i]
+		}
+	}
+	return found
+}
+
+// postUploadActions collects the after_upload actions of a run. They are
+// applied once all files were scanned, so pair detection still finds both
+// halves of a pair on disk, and the halves of a pair are moved together.
+// Only uploaded files are ever deleted.
+type postUploadActions struct {
+	files map[string]*postUploadFile
+	order []string
+}
+
+type postUploadFile struct {
+	source *config.Source
+	pair   *pairing.Pair
+	// leftOut is set for a half of a pair the pair policy did not upload
+	leftOut bool
+}
+
+func newPostUploadActions() *postUploadActions {
+	return &postUploadActions{files: make(map[string]*postUploadFile)}
+}
+
+// add records a file that was uploaded, now or by an earlier run
+func (p *postUploadActions) add(source *config.Source, file *scannedFile) {
+	p.record(source, file, false)
+}
+
+// addLeftOut records a half of a pair that the pair policy left out, so its
+// uploaded half is not kept waiting for it. It is never deleted, only moved
+// along with the uploaded half.
+func (p *postUploadActions) addLeftOut(source *config.Source, file *scannedFile) {
+	if file.pair == nil {
+		return
+	}
+	p.record(source, file, true)
+}
+
+func (p *postUploadActions) record(source *config.Source, file *scannedFile, leftOut bool) {
+	if source == nil || source.AfterUpload == "" || source.AfterUpload == config.AfterUploadKeep {
+		return
+	}
+	if _, ok := p.files[file.path]; !ok {
+		p.order = append(p.order, file.path)
+	}
+	p.files[file.path] = &postUploadFile{source: source, pair: file.pair, leftOut: leftOut}
+}
+
+// apply moves or deletes the recorded files. A file whose pair is not done
+// yet, because its upload failed or was not reached, is kept with it, and a
+// half left out is only moved when the other half was uploaded.
+func (p *postUploadActions) apply() {
+	for _, path := range p.order {
+		file := p.files[path]
+		if file.pair != nil {
+			other := file.pair.Companion
+			if path == other {
+				other = file.pair.Primary
+			}
+			otherFile, ok := p.files[other]
+			if !ok || (file.leftOut && otherFile.leftOut) {
+				slog.Info("Keeping file until its pair is uploaded", "path", path, "pair", other)
+				continue
+			}
+		}
+		if file.leftOut && file.source.AfterUpload != config.AfterUploadMove {
+			slog.Info("Keeping file that was not uploaded", "path", path)
+			continue
+		}
+		afterUpload(file.source, path)
+	}
+}
+
+// afterUpload applies the post-upload action of a source to an uploaded
+// file. Failures are logged, since the upload itself succeeded.
+func afterUpload(source *config.Source, path string) {
+	if source == nil {
+		return
+	}
+
+	switch source.AfterUpload {
+	case config.AfterUploadDelete:
+		if err := os.Remove(path); err != nil {
+			slog.Error("Failed to delete uploaded file", "path", path, "err", err)
+			return
+		}
+		slog.Info("Deleted uploaded file", "path", path)
+	case config.AfterUploadMove:
+		rel, err := filepath.Rel(source.Path, path)
+		if err != nil {
+			rel = filepath.Base(path)
+		}
+		dest := filepath.Join(source.MoveTo, rel)
+		if err := moveFile(path, dest); err != nil {
+			slog.Error("Failed to move uploaded file", "path", path, "to", dest, "err", err)
+			return
+		}
+		slog.Info("Moved uploaded file", "path", path, "to", dest)
+	}
+}
+
+// moveFile moves src to dst, creating the folder of dst. Existing files are
+// never overwritten. Across file systems the file is copied and the
+// original removed.
+func moveFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("%s already exists", dst)
+	}
+
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	if err := copyFile(src, dst); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
+
+// copyFile copies src to a new file dst, keeping its mode and modification
+// time
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
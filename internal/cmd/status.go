@@ -2,12 +2,16 @@ package cmd
 
 import (
 	"fmt"
+	"log/slog"
 	"path/filepath"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/navaneethkn/cronocam/internal/auth"
 	"github.com/navaneethkn/cronocam/internal/config"
 	"github.com/navaneethkn/cronocam/internal/db"
+	"github.com/navaneethkn/cronocam/internal/lock"
+	"github.com/navaneethkn/cronocam/internal/pairing"
 )
 
 var statusCmd = &cobra.Command{
@@ -17,20 +21,31 @@ var statusCmd = &cobra.Command{
 - Number of files uploaded to Google Photos
 - Pending files to upload
 - Any upload errors
-- Last upload time`,
+- Last upload time, last run and authentication state
+
+Use --output json or --output yaml for a machine-readable report. Its
+schema is described in the README and stays stable between releases.`,
 	RunE: runStatus,
 }
 
 func init() {
 	rootCmd.AddCommand(statusCmd)
+	addOutputFlag(statusCmd)
 }
 
 func runStatus(cmd *cobra.Command, args []string) error {
-	// Print paths
-	if err := printPaths(); err != nil {
+	format, err := outputFormat(cmd)
+	if err != nil {
 		return err
 	}
 
+	// Print paths
+	if format == outputTable {
+		if err := printPaths(); err != nil {
+			return err
+		}
+	}
+
 	// Open database
 	database, err := db.New(config.GetDatabasePath())
 	if err != nil {
@@ -50,15 +65,64 @@ func runStatus(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get pending files: %v", err)
 	}
 
+	// Get pending and failed file counts
+	pending, err := database.GetPendingCounts(time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to get pending counts: %v", err)
+	}
+
+	// Get paired file statistics
+	pairStats, err := database.GetPairStats()
+	if err != nil {
+		return fmt.Errorf("failed to get pair statistics: %v", err)
+	}
+
 	// Get recent errors
 	errors, err := database.GetRecentErrors()
 	if err != nil {
 		return fmt.Errorf("failed to get recent errors: %v", err)
 	}
 
+	// Get the last run
+	runs, err := database.GetRuns(1)
+	if err != nil {
+		return fmt.Errorf("failed to get runs: %v", err)
+	}
+	var lastRun *db.Run
+	if len(runs) > 0 {
+		lastRun = &runs[0]
+	}
+
+	// Get the active run, if any
+	holder, err := lock.Read(config.GetLockPath())
+	if err != nil {
+		return fmt.Errorf("failed to read lock: %v", err)
+	}
+
+	authState := readAuthState()
+
+	if format != outputTable {
+		return writeOutput(format, newStatusOutput(stats, pending, pairStats, errors, lastRun, holder, authState))
+	}
+
 	// Format output
 	fmt.Printf("Upload Status:\n")
 	fmt.Printf("-------------\n")
+	switch {
+	case holder == nil:
+		fmt.Printf("Active run: None\n")
+	case lock.IsStale(holder):
+		fmt.Printf("Active run: None (stale lock from %s)\n", holder)
+	default:
+		fmt.Printf("Active run: %s\n", holder)
+	}
+	if lastRun != nil {
+		fmt.Printf("Last run: %s %s, %s (run %d)\n", lastRun.Command,
+			formatRelativeTime(lastRun.StartedAt), formatRunStatus(lastRun), lastRun.ID)
+	} else {
+		fmt.Printf("Last run: Never\n")
+	}
+	fmt.Printf("Auth: %s\n", authState.State)
 	fmt.Printf("Total uploaded: %d file%s\n", stats.TotalUploaded, pluralize(int(stats.TotalUploaded)))
 	fmt.Printf("Total failed: %d file%s\n", stats.TotalErrors, pluralize(int(stats.TotalErrors)))
 	
@@ -79,17 +143,134 @@ func runStatus(cmd *cobra.Command, args []string) error {
 			fmt.Printf("- %s\n", filepath.Base(file))
 		}
 	}
+	if pending.RetryDue+pending.RetryScheduled+pending.FailedPermanently > 0 {
+		fmt.Printf("Failed files: %d due for retry, %d scheduled, %d permanent\n",
+			pending.RetryDue, pending.RetryScheduled, pending.FailedPermanently)
+	}
+
+	if len(pairStats) > 0 {
+		fmt.Printf("\nPaired Files:\n")
+		for _, ps := range pairStats {
+			label := pairLabels[ps.Kind]
+			if label.name == "" {
+				label = pairLabel{name: ps.Kind, primary: "primary", companion: "companion"}
+			}
+			fmt.Printf("- %s: %d pair%s (%s uploaded: %d, %s uploaded: %d)\n",
+				label.name, ps.Total, pluralize(int(ps.Total)),
+				label.primary, ps.PrimaryUploaded, label.companion, ps.CompanionUploaded)
+		}
+	}
 
 	if len(errors) > 0 {
 		fmt.Printf("\nRecent Errors:\n")
 		for _, err := range errors {
-			fmt.Printf("- %s: %s\n", filepath.Base(err.File), err.Message)
+			if err.Category != "" {
+				fmt.Printf("- %s [%s]: %s\n", filepath.Base(err.File), err.Category, err.Message)
+			} else {
+				fmt.Printf("- %s: %s\n", filepath.Base(err.File), err.Message)
+			}
 		}
 	}
 
 	return nil
 }
 
+// readAuthState reports the state of the stored OAuth token without
+// contacting Google
+func readAuthState() authOutput {
+	if _, err := tokenKey(); err != nil {
+		slog.Warn("No usable token encryption key", "err", err)
+		return authOutput{State: auth.TokenInvalid}
+	}
+	authenticator, err := newAuthenticator()
+	if err != nil {
+		return authOutput{State: "no_credentials"}
+	}
+	return newAuthOutput(authenticator.TokenStatus())
+}
+
+// newAuthOutput builds the structured output of the stored token
+func newAuthOutput(status auth.TokenStatus) authOutput {
+	return authOutput{
+		State:           status.State,
+		TokenExpiry:     status.Expiry,
+		HasRefreshToken: status.HasRefreshToken,
+		Encrypted:       status.Encrypted,
+		Scopes:          status.Scopes,
+		MissingScopes:   status.MissingScopes,
+	}
+}
+
+// newStatusOutput builds the structured status output
+func newStatusOutput(stats *db.UploadStats, pending *db.PendingCounts, pairStats []db.PairStats,
+	errors []db.UploadError, lastRun *db.Run, holder *lock.Info, authState authOutput) *statusOutput {
+	out := &statusOutput{
+		SchemaVersion: statusSchemaVersion,
+		Profile:       config.GetProfile(),
+		Paths: pathsOutput{
+			Credentials: absPath(config.GetCredentialsPath()),
+			Token:       absPath(config.GetTokenPath()),
+			Database:    absPath(config.GetDatabasePath()),
+		},
+		Stats: statsOutput{
+			TotalUploaded:  stats.TotalUploaded,
+			TotalErrors:    stats.TotalErrors,
+			LastUploadTime: stats.LastUploadTime,
+		},
+		Pending: pendingOutput{
+			Imported:          pending.Imported,
+			RetryDue:          pending.RetryDue,
+			RetryScheduled:    pending.RetryScheduled,
+			FailedPermanently: pending.FailedPermanently,
+		},
+		Pairs:        []pairOutput{},
+		RecentErrors: newErrorOutputs(errors),
+		Auth:         authState,
+	}
+	for _, ps := range pairStats {
+		out.Pairs = append(out.Pairs, pairOutput{
+			Kind:              ps.Kind,
+			Total:             ps.Total,
+			PrimaryUploaded:   ps.PrimaryUploaded,
+			CompanionUploaded: ps.CompanionUploaded,
+		})
+	}
+	if lastRun != nil {
+		out.LastRun = newRunOutput(lastRun)
+	}
+	if holder != nil {
+		out.ActiveRun = &activeRunOutput{
+			Command:   holder.Command,
+			PID:       holder.PID,
+			Host:      holder.Host,
+			StartedAt: holder.StartedAt,
+			Stale:     lock.IsStale(holder),
+		}
+	}
+	return out
+}
+
+// absPath returns the absolute form of path, or path itself if it cannot
+// be resolved
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// pairLabel names a kind of file pair and its two halves in status output
+type pairLabel struct {
+	name      string
+	primary   string
+	companion string
+}
+
+var pairLabels = map[string]pairLabel{
+	string(pairing.LivePhoto): {name: "Live Photos", primary: "photos", companion: "videos"},
+	string(pairing.RawJPEG):   {name: "RAW+JPEG", primary: "JPEG", companion: "RAW"},
+}
+
 func formatRelativeTime(t time.Time) string {
 	now := time.Now()
 	diff := now.Sub(t)
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/navaneethkn/cronocam/internal/config"
+	"github.com/navaneethkn/cronocam/internal/db"
+	"github.com/navaneethkn/cronocam/internal/metadata"
+	"github.com/navaneethkn/cronocam/internal/takeout"
+	"github.com/navaneethkn/cronocam/internal/uploader"
+)
+
+// readTakeoutSidecar looks up the Takeout sidecar of a file and fills in any
+// capture time or location the file's own metadata lacks. It returns nil when
+// Takeout mode is off or the file has no sidecar.
+func readTakeoutSidecar(sidecars *takeout.Index, path string, meta *metadata.Metadata) *takeout.Sidecar {
+	if sidecars == nil {
+		return nil
+	}
+
+	sidecar, err := sidecars.Lookup(path)
+	if err != nil {
+		slog.Warn("Failed to read Takeout sidecar", "path", path, "err", err)
+		return nil
+	}
+	if sidecar == nil {
+		return nil
+	}
+
+	if meta.TakenAt == nil && sidecar.TakenAt != nil {
+		meta.TakenAt = sidecar.TakenAt
+	}
+	if !meta.HasLocation() && sidecar.Latitude != nil {
+		meta.Latitude, meta.Longitude = sidecar.Latitude, sidecar.Longitude
+	}
+	return sidecar
+}
+
+// ensureAlbum returns the ID of the album with the given title, creating it
+// in Google Photos the first time it is needed
+func ensureAlbum(ctx context.Context, database *db.DB, photoUploader *uploader.Uploader, title string) (string, error) {
+	albumID, err := database.GetAlbumID(title)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up album %q: %v", title, err)
+	}
+	if albumID != "" {
+		return albumID, nil
+	}
+
+	albumID, err = photoUploader.CreateAlbum(ctx, title)
+	if err != nil {
+		return "", fmt.Errorf("failed to create album %q: %w", title, err)
+	}
+	if err := database.SaveAlbum(title, albumID); err != nil {
+		return "", fmt.Errorf("failed to save album %q: %v", title, err)
+	}
+
+	slog.Info("Created album", "title", title)
+	return albumID, nil
+}
+
+// addUploadedToAlbum adds a file whose content was uploaded before to the
+// album it belongs in. A Takeout photo is in its year folder and in each of
+// its album folders, and only the first copy is uploaded. Failures are
+// logged, since the file itself is uploaded.
+func addUploadedToAlbum(ctx context.Context, database *db.DB, photoUploader *uploader.Uploader, source *config.Source, file *scannedFile, hash string, opts uploadOptions) {
+	album := albumOf(source, file, opts)
+	if album == "" {
+		return
+	}
+	googleID, err := database.GetGoogleID(hash)
+	if err != nil {
+		slog.Error("Failed to look up uploaded file", "path", file.path, "err", err)
+		return
+	}
+	if googleID == "" {
+		// Imported, not uploaded by cronocam
+		return
+	}
+
+	albumID, err := ensureAlbum(ctx, database, photoUploader, album)
+	if err != nil {
+		slog.Error("Failed to add to album", "path", file.path, "album", album, "err", err)
+		return
+	}
+	inAlbum, err := database.IsInAlbum(albumID, googleID)
+	if err != nil {
+		slog.Error("Failed to check album membership", "path", file.path, "album", album, "err", err)
+		return
+	}
+	if inAlbum {
+		return
+	}
+	if err := photoUploader.AddToAlbum(ctx, albumID, []string{googleID}); err != nil {
+		slog.Error("Failed to add to album", "path", file.path, "album", album, "err", err)
+		return
+	}
+	if err := database.SaveAlbumItem(albumID, googleID); err != nil {
+		slog.Error("Failed to save album item", "path", file.path, "err", err)
+	}
+	slog.Info("Added to album", "path", file.path, "album", album)
+}
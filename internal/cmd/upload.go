@@ -2,12 +2,15 @@ package cmd
 
 import (
 	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/navaneethkn/cronocam/internal/config"
 	"github.com/navaneethkn/cronocam/internal/db"
+	"github.com/navaneethkn/cronocam/internal/filter"
 	"github.com/spf13/cobra"
 )
 
@@ -18,29 +21,83 @@ var uploadCmd = &cobra.Command{
 Recursively searches for supported image files and uploads them.
 Tracks uploaded files to avoid duplicates.
 
+Without a directory every source listed under sources in the config file is
+uploaded with its own recursion, include and exclude patterns, album policy
+and post-upload action. --source uploads a single one of them.
+
 You can also provide a text file containing a list of file paths to upload
 using the --file-list option. Each line in the file should be a full path
 to a photo or video file.
 
-Use --retry-failed to retry uploading files that previously failed to upload.
-This will attempt to upload any files that are in the error log but not yet
-successfully uploaded.`,
+Only one upload or import runs against a database at a time. If another
+run holds the lock the command exits with an error, unless --wait is given
+to wait for it to finish.
+
+Failures are classified as network, rate limit, auth, invalid media or local
+I/O errors. Network and rate limit failures are retried automatically by
+later runs with exponential backoff; the others are left alone until you
+fix the cause. Use --retry-failed to retry every file that is in the error
+log but not yet successfully uploaded, regardless of its schedule.
+
+Filters such as --taken-after, --camera or --type limit which files are
+processed. Capture dates come from the file metadata when available and
+fall back to the file modification time.
+
+Use --dry-run to see what an upload would do before running it. The files
+are scanned, filtered, hashed and checked against the database exactly as
+for an upload, then a plan lists the files to upload with their sizes, the
+duplicates, the skipped files with the reason and the total size. Nothing
+is sent and nothing is written to the database. Add --output json or yaml
+to export the plan.
+
+When stdout is a terminal, a status line shows the files and bytes done,
+the current throughput, an estimate of the time left and the progress of
+the file being uploaded. Otherwise a progress summary is logged every
+minute. --no-progress turns both off.
+
+Use --takeout when uploading a Google Takeout export. Each media file is
+paired with its JSON sidecar, whose description and capture time are used
+for the upload, and files in album folders are added to an album of the
+same name.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runUpload,
 }
 
 func init() {
 	rootCmd.AddCommand(uploadCmd)
+	addUploadFlags(uploadCmd)
+}
 
-	// Add flags
-	uploadCmd.Flags().BoolP("recursive", "r", true, "recursively search for files in subdirectories")
-	uploadCmd.Flags().Int64P("max-files", "m", 0, "maximum number of files to upload (0 for unlimited)")
-	uploadCmd.Flags().BoolP("force", "f", false, "force upload even if file was previously uploaded")
-	uploadCmd.Flags().StringP("file-list", "l", "", "path to text file containing list of files to upload")
-	uploadCmd.Flags().BoolP("retry-failed", "x", false, "retry uploading previously failed files")
+// addUploadFlags registers the upload flags. Daemon jobs parse their flags
+// with the same set.
+func addUploadFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolP("recursive", "r", true, "recursively search for files in subdirectories")
+	cmd.Flags().Int64P("max-files", "m", 0, "maximum number of files to upload (0 for unlimited)")
+	cmd.Flags().BoolP("force", "f", false, "force upload even if file was previously uploaded")
+	cmd.Flags().StringP("file-list", "l", "", "path to text file containing list of files to upload")
+	cmd.Flags().String("source", "", "upload only the configured source with this name")
+	cmd.Flags().BoolP("retry-failed", "x", false, "retry all previously failed files, including permanent failures")
+	addLockFlags(cmd)
+	cmd.Flags().Bool("takeout", false, "read Google Takeout JSON sidecars and album folders")
+	cmd.Flags().Bool("no-progress", false, "do not show progress or log periodic progress summaries")
+	cmd.Flags().Bool("dry-run", false, "scan, filter, hash and check for duplicates, then print the plan without uploading")
+	addOutputFlag(cmd)
+	addFilterFlags(cmd)
+}
+
+// uploadSession is shared by the uploads of a long-running process. The
+// daemon authenticates once and serves the metrics for all its jobs.
+type uploadSession struct {
+	client *http.Client
 }
 
 func runUpload(cmd *cobra.Command, args []string) error {
+	return upload(cmd, args, nil)
+}
+
+// upload runs an upload with the flags of cmd. session is nil for a single
+// upload, which authenticates and serves metrics itself.
+func upload(cmd *cobra.Command, args []string, session *uploadSession) (err error) {
 	// Get flags
 	var recursive bool
 	var force bool
@@ -52,29 +109,146 @@ func runUpload(cmd *cobra.Command, args []string) error {
 	maxFiles, _ = cmd.Flags().GetInt64("max-files")
 	fileList, _ = cmd.Flags().GetString("file-list")
 	retryFailed, _ = cmd.Flags().GetBool("retry-failed")
+	takeoutMode, _ := cmd.Flags().GetBool("takeout")
+	noProgress, _ := cmd.Flags().GetBool("no-progress")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	sourceName, _ := cmd.Flags().GetString("source")
+
+	if sourceName != "" && (len(args) > 0 || fileList != "" || retryFailed) {
+		return fmt.Errorf("--source cannot be combined with a directory, --file-list or --retry-failed")
+	}
+
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+	if format != outputTable && !dryRun {
+		return fmt.Errorf("--output requires --dry-run")
+	}
+	// Paths and notices would corrupt a plan written as JSON or YAML, and
+	// only clutter the output of the daemon
+	quiet := format != outputTable || session != nil
+
+	filterOpts, err := filterOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	fileFilter, err := filter.New(filterOpts)
+	if err != nil {
+		return err
+	}
+
+	sources, err := config.GetSources()
+	if err != nil {
+		return err
+	}
+
+	livePhotoVideo, err := config.GetLivePhotoVideo()
+	if err != nil {
+		return err
+	}
 
-	// Initialize database for getting failed files
-	database, err := db.New(config.GetDatabasePath())
+	rawPolicy, err := config.GetRawPolicy()
+	if err != nil {
+		return err
+	}
+
+	// Initialize database. A dry run only reads it, so it is neither
+	// recorded nor locked and can run next to a real upload.
+	openDB := db.New
+	if dryRun {
+		openDB = db.OpenReadOnly
+	}
+	database, err := openDB(config.GetDatabasePath())
 	if err != nil {
 		return fmt.Errorf("failed to initialize database: %v", err)
 	}
 	defer database.Close()
 
+	var plan *uploadPlan
+	var display *progress
+	run := &db.Run{Command: cmd.Name()}
+	if dryRun {
+		plan = newUploadPlan()
+		defer func() {
+			if err != nil {
+				return
+			}
+			if format != outputTable {
+				err = writeOutput(format, plan.planOutput)
+				return
+			}
+			plan.print()
+		}()
+	} else {
+		// Record this run in the run history, including lock failures
+		run = startRun(database, cmd, args)
+		defer func() { finishRun(database, run, err) }()
+
+		// Make sure no other run is working on the same database
+		runLock, err := acquireRunLock(cmd)
+		if err != nil {
+			return err
+		}
+		defer releaseRunLock(runLock)
+
+		if session == nil {
+			stopMetrics, err := serveMetrics()
+			if err != nil {
+				return fmt.Errorf("failed to serve metrics: %v", err)
+			}
+			defer stopMetrics()
+		}
+
+		if !noProgress {
+			display = newProgress()
+			defer display.Close()
+		}
+	}
+
+	opts := uploadOptions{
+		force:          force,
+		maxFiles:       maxFiles,
+		filter:         fileFilter,
+		takeout:        takeoutMode,
+		livePhotoVideo: livePhotoVideo,
+		rawPolicy:      rawPolicy,
+		retryFailed:    retryFailed,
+		stats:          runCounts{RunStats: &run.RunStats, progress: display},
+		plan:           plan,
+		retried:        make(map[string]bool),
+		sources:        sources,
+	}
+	if session != nil {
+		opts.client = session.client
+	}
+
 	// Get list of files to upload
 	var files []string
 
 	// If retrying failed files
 	if retryFailed {
-		// Get failed files from database
+		// Get failed files from database, including permanent failures
 		files, err = database.GetFailedFiles()
 		if err != nil {
 			return fmt.Errorf("failed to get failed files: %v", err)
 		}
 		if len(files) == 0 {
-			fmt.Println("No failed files to retry")
+			if !quiet {
+				fmt.Println("No failed files to retry")
+			}
 			return nil
 		}
-		fmt.Printf("Found %d failed files to retry\n", len(files))
+		if !quiet {
+			fmt.Printf("Found %d failed files to retry\n", len(files))
+
+			// Print paths
+			if err := printPaths(); err != nil {
+				return err
+			}
+		}
+
+		return uploadFiles(database, files, opts)
 	} else if fileList != "" {
 		// Read file paths from text file
 		fileData, err := os.ReadFile(fileList)
@@ -98,71 +272,120 @@ func runUpload(cmd *cobra.Command, args []string) error {
 		}
 
 		// Print paths
-		if err := printPaths(); err != nil {
+		if !quiet {
+			if err := printPaths(); err != nil {
+				return err
+			}
+		}
+
+		// Retry transient failures that are due
+		if err := retryDueFiles(database, opts); err != nil {
 			return err
 		}
 
 		// Start upload process
-		return uploadFiles(files, force, maxFiles)
+		return uploadFiles(database, files, opts)
 	}
 
-	// Using directory mode
-	if len(args) == 0 {
-		return fmt.Errorf("directory path required when not using --file-list")
+	// Using directory mode: the directory argument, one source or all of
+	// them
+	switch {
+	case sourceName != "":
+		source, err := config.GetSource(sourceName)
+		if err != nil {
+			return err
+		}
+		sources = []config.Source{*source}
+	case len(args) > 0:
+		// Convert to absolute path
+		absPath, err := filepath.Abs(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path: %v", err)
+		}
+		sources = []config.Source{{Name: absPath, Path: absPath, Recursive: &recursive}}
+	case len(sources) == 0:
+		return fmt.Errorf("directory path required when not using --file-list or configured sources")
 	}
 
-	// Get directory path from args
-	dirPath := args[0]
-
-	// These variables were already declared at the top
-	recursive, _ = cmd.Flags().GetBool("recursive")
-	force, _ = cmd.Flags().GetBool("force")
-	maxFiles, _ = cmd.Flags().GetInt64("max-files")
-
-	// Convert to absolute path
-	absPath, err := filepath.Abs(dirPath)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %v", err)
+	// Validate upload directories
+	for _, source := range sources {
+		info, err := os.Stat(source.Path)
+		if err != nil {
+			return fmt.Errorf("failed to access directory %s: %v", source.Path, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", source.Path)
+		}
 	}
 
-	// Validate upload directory
-	info, err := os.Stat(absPath)
-	if err != nil {
-		return fmt.Errorf("failed to access directory %s: %v", absPath, err)
-	}
-	if !info.IsDir() {
-		return fmt.Errorf("%s is not a directory", absPath)
+	// Validate credentials file first, unless nothing is going to be sent
+	if !dryRun {
+		credentialsPath, err := filepath.Abs(config.GetCredentialsPath())
+		if err != nil {
+			return fmt.Errorf("failed to get absolute credentials path: %v", err)
+		}
+		info, err := os.Stat(credentialsPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("credentials file not found at %s - please create it first", credentialsPath)
+			}
+			return fmt.Errorf("failed to access credentials file: %v", err)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("%s is a directory, expected a file", credentialsPath)
+		}
 	}
 
-	// Validate credentials file first
-	credentialsPath, err := filepath.Abs(config.GetCredentialsPath())
-	if err != nil {
-		return fmt.Errorf("failed to get absolute credentials path: %v", err)
-	}
-	info, err = os.Stat(credentialsPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("credentials file not found at %s - please create it first", credentialsPath)
+	// Print paths
+	if !quiet {
+		if err := printPaths(); err != nil {
+			return err
 		}
-		return fmt.Errorf("failed to access credentials file: %v", err)
 	}
-	if info.IsDir() {
-		return fmt.Errorf("%s is a directory, expected a file", credentialsPath)
+
+	// Ensure required directories exist
+	if !dryRun {
+		if err := config.EnsureDirectories(); err != nil {
+			return fmt.Errorf("failed to create directories: %v", err)
+		}
 	}
 
-	// Print paths
-	if err := printPaths(); err != nil {
+	// Retry transient failures that are due
+	if err := retryDueFiles(database, opts); err != nil {
 		return err
 	}
 
-	// Set upload path in config
-	config.SetUploadPath(absPath)
+	// Start upload process. The settings of a source apply unless they are
+	// overridden by flags.
+	failed := 0
+	for i := range sources {
+		source := &sources[i]
+		sourceRecursive := source.IsRecursive()
+		if cmd.Flags().Changed("recursive") {
+			sourceRecursive = recursive
+		}
 
-	// Ensure required directories exist
-	if err := config.EnsureDirectories(); err != nil {
-		return fmt.Errorf("failed to create directories: %v", err)
-	}
+		sourceFilter := filterOpts
+		sourceFilter.Root = source.Path
+		sourceFilter.Include = source.Include
+		sourceFilter.Exclude = source.Exclude
+		if opts.filter, err = filter.New(sourceFilter); err != nil {
+			return fmt.Errorf("source %s: %v", source.Name, err)
+		}
 
-	// Start upload process
-	return uploadPhotos(recursive, force, maxFiles)
+		if len(sources) > 1 {
+			slog.Info("Uploading source", "source", source.Name, "path", source.Path)
+		}
+		if err := uploadPhotos(database, source, sourceRecursive, opts); err != nil {
+			if len(sources) == 1 {
+				return err
+			}
+			slog.Error("Failed to upload source", "source", source.Name, "err", err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d sources failed", failed, len(sources))
+	}
+	return nil
 }
@@ -2,10 +2,14 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -19,35 +23,208 @@ const (
 	DefaultReqPerSec       = 5
 	DefaultMaxBurst        = 10
 
+	// DefaultDescriptionTemplate reproduces the historical filename description
+	DefaultDescriptionTemplate = "{{.Filename}}"
+
+	// Live Photo video policies
+	LivePhotoVideoUpload = "upload" // upload the video as a separate item
+	LivePhotoVideoSkip   = "skip"   // upload only the still image
+	LivePhotoVideoAlbum  = "album"  // upload both halves into one album
+
+	DefaultLivePhotoVideo = LivePhotoVideoUpload
+	DefaultLivePhotoAlbum = "Live Photos"
+
+	// RAW+JPEG pair policies
+	RawPolicyJPEG        = "jpeg"           // upload JPEGs only, never RAW files
+	RawPolicyRAW         = "raw"            // upload the RAW file of a pair, not its JPEG
+	RawPolicyBoth        = "both"           // upload both files of a pair
+	RawPolicyRAWIfNoJPEG = "raw_if_no_jpeg" // upload RAW files only when no JPEG exists
+
+	DefaultRawPolicy = RawPolicyJPEG
+
+	// Retry schedule for failed uploads
+	DefaultRetryInitialDelay = 15 * time.Minute
+	DefaultRetryMaxDelay     = 24 * time.Hour
+	DefaultRetryMaxAttempts  = 10
+
+	// Logging
+	DefaultLogLevel      = "info"
+	DefaultLogFormat     = "text"
+	DefaultLogMaxSizeMB  = 10
+	DefaultLogMaxBackups = 5
+
+	// DefaultHookTimeout is how long a hook command may run
+	DefaultHookTimeout = 30 * time.Second
+
+	// Webhook notification triggers
+	NotifyAlways  = "always"  // after every run
+	NotifyFailure = "failure" // after runs that failed or had failed files
+
+	DefaultNotifyRetries    = 3
+	DefaultNotifyRetryDelay = 5 * time.Second
+	DefaultNotifyTimeout    = 10 * time.Second
+
+	// Album policies of sources
+	AlbumPolicyNone   = "none"   // do not add files to an album
+	AlbumPolicySource = "source" // one album named after the source
+	AlbumPolicyFolder = "folder" // one album per folder, named after it
+	AlbumPolicyFixed  = "fixed"  // the album set in album
+
+	// Actions taken on a local file once it is uploaded
+	AfterUploadKeep   = "keep"   // leave the file alone
+	AfterUploadDelete = "delete" // delete the file
+	AfterUploadMove   = "move"   // move the file below move_to
+
+	// DefaultDaemonJitter is the largest random delay added to daemon runs
+	DefaultDaemonJitter = time.Minute
+
+	// Token encryption modes
+	TokenEncryptionNone       = "none"       // store the token in plain text
+	TokenEncryptionPassphrase = "passphrase" // derive the key from a passphrase
+	TokenEncryptionMachineID  = "machine-id" // derive the key from the machine id
+
+	DefaultTokenEncryption = TokenEncryptionNone
+
+	// TokenPassphraseEnv holds the token passphrase. It takes precedence
+	// over token.passphrase_file.
+	TokenPassphraseEnv = "PHOTOS_TOKEN_PASSPHRASE"
+
 	// Default supported file formats
 	DefaultSupportedImages = ".jpg,.jpeg,.png,.gif,.heic,.heif,.webp,.tiff,.tif,.bmp"
 	DefaultSupportedVideos = ".mpg,.mpeg,.avi,.mov,.mp4,.m4v,.wmv,.3gp,.3g2,.mkv,.mts,.m2ts"
+	DefaultSupportedRaw    = ".cr2,.cr3,.nef,.nrw,.arw,.srf,.sr2,.dng,.orf,.rw2,.raf,.pef,.srw"
 )
 
 var (
 	v    *viper.Viper
 	once sync.Once
+	// profile is the selected entry of profiles, or empty
+	profile string
 )
 
-// Initialize sets up the viper configuration
+// validProfileName restricts profile names to what is safe in file names
+var validProfileName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Webhook is an entry of notify.webhooks
+type Webhook struct {
+	URL string `mapstructure:"url"`
+	// Name identifies the webhook in logs; it defaults to the scheme and
+	// host of the URL, leaving out tokens in its path and query
+	Name string `mapstructure:"name"`
+	// When is NotifyAlways or NotifyFailure
+	When string `mapstructure:"when"`
+	// MinFailures is the number of failed files that makes a run count as
+	// failed for NotifyFailure, even if it exited successfully
+	MinFailures int `mapstructure:"min_failures"`
+	// Template renders the payload; empty posts the run summary as JSON
+	Template    string            `mapstructure:"template"`
+	ContentType string            `mapstructure:"content_type"`
+	Headers     map[string]string `mapstructure:"headers"`
+}
+
+// Job is an entry of daemon.jobs
+type Job struct {
+	// Name identifies the job in logs; it defaults to the source
+	Name string `mapstructure:"name"`
+	// Source is the directory to upload; jobs of sources leave it empty
+	Source string `mapstructure:"source"`
+	// Schedule is a cron expression
+	Schedule string `mapstructure:"schedule"`
+	// Flags are upload flags such as --takeout or --max-files=100
+	Flags []string `mapstructure:"flags"`
+	// QuietHours is an optional HH:MM-HH:MM window in which runs do not start
+	QuietHours string `mapstructure:"quiet_hours"`
+	// Profile runs the job as this profile; it defaults to the selected one
+	Profile string `mapstructure:"profile"`
+}
+
+// Source is an entry of sources, a directory uploaded with its own settings
+type Source struct {
+	Name string `mapstructure:"name"`
+	Path string `mapstructure:"path"`
+	// Recursive defaults to true
+	Recursive *bool `mapstructure:"recursive"`
+	// Include and Exclude are glob patterns, see filter.Options
+	Include []string `mapstructure:"include"`
+	Exclude []string `mapstructure:"exclude"`
+	// AlbumPolicy is one of the AlbumPolicy* values, Album the album name
+	// of AlbumPolicyFixed
+	AlbumPolicy string `mapstructure:"album_policy"`
+	Album       string `mapstructure:"album"`
+	// AfterUpload is one of the AfterUpload* values, MoveTo the directory
+	// of AfterUploadMove
+	AfterUpload string `mapstructure:"after_upload"`
+	MoveTo      string `mapstructure:"move_to"`
+	// Schedule and QuietHours make the daemon upload the source, see Job
+	Schedule   string `mapstructure:"schedule"`
+	QuietHours string `mapstructure:"quiet_hours"`
+}
+
+// IsRecursive reports whether folders below the source path are uploaded
+func (s *Source) IsRecursive() bool {
+	return s.Recursive == nil || *s.Recursive
+}
+
+// AlbumFor returns the album a file of the source is added to, or an empty
+// string
+func (s *Source) AlbumFor(path string) string {
+	switch s.AlbumPolicy {
+	case AlbumPolicySource:
+		return s.Name
+	case AlbumPolicyFolder:
+		return filepath.Base(filepath.Dir(path))
+	case AlbumPolicyFixed:
+		return s.Album
+	}
+	return ""
+}
+
+// Contains reports whether path lies below the source path
+func (s *Source) Contains(path string) bool {
+	rel, err := filepath.Rel(s.Path, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
 // GetSupportedFormats returns a map of supported file extensions
 func GetSupportedFormats() map[string]bool {
-	// Get configured formats
-	imageFormats := strings.Split(v.GetString("supported_images"), ",")
-	videoFormats := strings.Split(v.GetString("supported_videos"), ",")
+	supported := GetSupportedImages()
+	for ext := range GetSupportedVideos() {
+		supported[ext] = true
+	}
+	for ext := range GetSupportedRaw() {
+		supported[ext] = true
+	}
+	return supported
+}
+
+// GetSupportedImages returns a map of supported image file extensions
+func GetSupportedImages() map[string]bool {
+	return parseFormats(v.GetString("supported_images"))
+}
 
-	// Build map of supported formats
+// GetSupportedVideos returns a map of supported video file extensions
+func GetSupportedVideos() map[string]bool {
+	return parseFormats(v.GetString("supported_videos"))
+}
+
+// GetSupportedRaw returns a map of supported camera RAW file extensions
+func GetSupportedRaw() map[string]bool {
+	return parseFormats(v.GetString("supported_raw"))
+}
+
+// parseFormats builds a set from a comma-separated list of extensions
+func parseFormats(formats string) map[string]bool {
 	supported := make(map[string]bool)
-	for _, ext := range append(imageFormats, videoFormats...) {
-		ext = strings.TrimSpace(ext)
+	for _, ext := range strings.Split(formats, ",") {
+		ext = strings.ToLower(strings.TrimSpace(ext))
 		if ext != "" {
 			supported[ext] = true
 		}
 	}
-
 	return supported
 }
 
+// Initialize sets up the viper configuration
 func Initialize(configFile string) error {
 	var initErr error
 	once.Do(func() {
@@ -62,6 +239,24 @@ func Initialize(configFile string) error {
 		v.SetDefault("rate_limit.max_burst", DefaultMaxBurst)
 		v.SetDefault("supported_images", DefaultSupportedImages)
 		v.SetDefault("supported_videos", DefaultSupportedVideos)
+		v.SetDefault("supported_raw", DefaultSupportedRaw)
+		v.SetDefault("raw.policy", DefaultRawPolicy)
+		v.SetDefault("description_template", DefaultDescriptionTemplate)
+		v.SetDefault("live_photos.video", DefaultLivePhotoVideo)
+		v.SetDefault("live_photos.album", DefaultLivePhotoAlbum)
+		v.SetDefault("retry.initial_delay", DefaultRetryInitialDelay)
+		v.SetDefault("retry.max_delay", DefaultRetryMaxDelay)
+		v.SetDefault("retry.max_attempts", DefaultRetryMaxAttempts)
+		v.SetDefault("log.level", DefaultLogLevel)
+		v.SetDefault("log.format", DefaultLogFormat)
+		v.SetDefault("log.max_size_mb", DefaultLogMaxSizeMB)
+		v.SetDefault("log.max_backups", DefaultLogMaxBackups)
+		v.SetDefault("hooks.timeout", DefaultHookTimeout)
+		v.SetDefault("notify.retries", DefaultNotifyRetries)
+		v.SetDefault("notify.retry_delay", DefaultNotifyRetryDelay)
+		v.SetDefault("notify.timeout", DefaultNotifyTimeout)
+		v.SetDefault("daemon.jitter", DefaultDaemonJitter)
+		v.SetDefault("token.encryption", DefaultTokenEncryption)
 
 		// Environment variables
 		v.SetEnvPrefix("PHOTOS")
@@ -97,40 +292,108 @@ func Initialize(configFile string) error {
 				// If config file was explicitly specified but can't be read, fail
 				initErr = fmt.Errorf("error reading config file: %v", err)
 				return
-			} else if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-				// Config file not found in default locations
-				fmt.Println("No config file found, using default values")
-			} else {
+			} else if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
 				// Config file found but has errors
 				initErr = fmt.Errorf("error reading config file: %v", err)
 				return
 			}
-		} else {
-			fmt.Printf("Using config file: %s\n", v.ConfigFileUsed())
+			// Otherwise no config file was found in the default locations
+			// and the defaults are used
 		}
 	})
 
 	return initErr
 }
 
-// GetCredentialsPath returns the configured credentials path
+// FileUsed returns the path of the config file that was read, or an empty
+// string when the defaults are used
+func FileUsed() string {
+	return v.ConfigFileUsed()
+}
+
+// SetProfile selects an entry of profiles. Each profile has its own token,
+// database and sources. An empty name selects the profile setting, which
+// is usually given as PHOTOS_PROFILE, or no profile at all.
+func SetProfile(name string) error {
+	if name == "" {
+		name = v.GetString("profile")
+	}
+	name = strings.ToLower(name)
+	if name == "" {
+		profile = ""
+		return nil
+	}
+	if !validProfileName.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use lowercase letters, digits, - and _", name)
+	}
+	if _, ok := v.GetStringMap("profiles")[name]; !ok {
+		return fmt.Errorf("no profile named %s under profiles in the config", name)
+	}
+	profile = name
+	return nil
+}
+
+// GetProfile returns the selected profile, or an empty string
+func GetProfile() string {
+	return profile
+}
+
+// GetProfiles returns the names of all configured profiles
+func GetProfiles() []string {
+	var names []string
+	for name := range v.GetStringMap("profiles") {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// profileString returns a setting of the selected profile, or an empty
+// string when no profile is selected or it does not set key
+func profileString(key string) string {
+	if profile == "" {
+		return ""
+	}
+	return v.GetString("profiles." + profile + "." + key)
+}
+
+// GetCredentialsPath returns the configured credentials path. Profiles share
+// the OAuth client.
 func GetCredentialsPath() string {
 	return v.GetString("credentials_path")
 }
 
-// GetDatabasePath returns the configured database path
-func GetDatabasePath() string {
-	return v.GetString("database_path")
+// GetTokenPath returns where the OAuth token is stored: token.json next to
+// the credentials, or in a folder named after the selected profile
+func GetTokenPath() string {
+	if path := profileString("token_path"); path != "" {
+		return path
+	}
+	dir := filepath.Dir(GetCredentialsPath())
+	if profile != "" {
+		dir = filepath.Join(dir, profile)
+	}
+	return filepath.Join(dir, "token.json")
 }
 
-// GetUploadPath returns the configured upload path
-func GetUploadPath() string {
-	return v.GetString("upload_path")
+// GetDatabasePath returns the configured database path. Without a
+// database_path of its own, a profile keeps its database in a folder named
+// after it next to the default one.
+func GetDatabasePath() string {
+	if path := profileString("database_path"); path != "" {
+		return path
+	}
+	path := v.GetString("database_path")
+	if profile != "" {
+		path = filepath.Join(filepath.Dir(path), profile, filepath.Base(path))
+	}
+	return path
 }
 
-// SetUploadPath sets the upload path
-func SetUploadPath(path string) {
-	v.Set("upload_path", path)
+// GetLockPath returns the path of the lock file that keeps runs from
+// overlapping. It lives next to the database it protects.
+func GetLockPath() string {
+	return GetDatabasePath() + ".lock"
 }
 
 // GetChunkSize returns the configured chunk size
@@ -153,6 +416,370 @@ func GetMaxBurst() int {
 	return v.GetInt("rate_limit.max_burst")
 }
 
+// GetDescriptionTemplate returns the text/template used for media item
+// descriptions. An empty template omits the description.
+func GetDescriptionTemplate() string {
+	return v.GetString("description_template")
+}
+
+// GetLivePhotoVideo returns how the video half of a Live Photo is handled
+func GetLivePhotoVideo() (string, error) {
+	policy := strings.ToLower(v.GetString("live_photos.video"))
+	switch policy {
+	case LivePhotoVideoUpload, LivePhotoVideoSkip, LivePhotoVideoAlbum:
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid live_photos.video %q (expected %s, %s or %s)",
+		policy, LivePhotoVideoUpload, LivePhotoVideoSkip, LivePhotoVideoAlbum)
+}
+
+// GetLivePhotoAlbum returns the album Live Photos are placed in when the
+// video policy is "album"
+func GetLivePhotoAlbum() string {
+	return v.GetString("live_photos.album")
+}
+
+// GetRawPolicy returns which files of a RAW+JPEG pair are uploaded
+func GetRawPolicy() (string, error) {
+	policy := strings.ToLower(v.GetString("raw.policy"))
+	switch policy {
+	case RawPolicyJPEG, RawPolicyRAW, RawPolicyBoth, RawPolicyRAWIfNoJPEG:
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid raw.policy %q (expected %s, %s, %s or %s)",
+		policy, RawPolicyJPEG, RawPolicyRAW, RawPolicyBoth, RawPolicyRAWIfNoJPEG)
+}
+
+// GetRetryInitialDelay returns how long to wait before retrying a file after
+// its first transient failure. The delay doubles with every further attempt.
+func GetRetryInitialDelay() time.Duration {
+	return v.GetDuration("retry.initial_delay")
+}
+
+// GetRetryMaxDelay returns the longest delay between two retries of a file
+func GetRetryMaxDelay() time.Duration {
+	return v.GetDuration("retry.max_delay")
+}
+
+// GetRetryMaxAttempts returns how many times a file is tried before its
+// failure is treated as permanent
+func GetRetryMaxAttempts() int {
+	return v.GetInt("retry.max_attempts")
+}
+
+// GetHeartbeatPath returns the file the heartbeat is written to after every
+// run, by default heartbeat.json next to the database
+func GetHeartbeatPath() string {
+	if path := v.GetString("heartbeat.path"); path != "" {
+		return path
+	}
+	return filepath.Join(filepath.Dir(GetDatabasePath()), "heartbeat.json")
+}
+
+// GetMetricsListen returns the address of the Prometheus metrics listener,
+// or an empty string when metrics are not served over HTTP
+func GetMetricsListen() string {
+	return v.GetString("metrics.listen")
+}
+
+// GetMetricsTextfile returns the file metrics are written to at the end of
+// each run for the node_exporter textfile collector, or an empty string
+func GetMetricsTextfile() string {
+	return v.GetString("metrics.textfile")
+}
+
+// GetLogLevel returns the minimum level of logged messages
+func GetLogLevel() string {
+	return v.GetString("log.level")
+}
+
+// GetLogFormat returns the log format, text or json
+func GetLogFormat() string {
+	return v.GetString("log.format")
+}
+
+// GetLogFile returns the file logs are written to instead of stderr, or an
+// empty string
+func GetLogFile() string {
+	return v.GetString("log.file")
+}
+
+// GetLogMaxSize returns the size in bytes at which the log file is rotated
+func GetLogMaxSize() int64 {
+	return v.GetInt64("log.max_size_mb") * 1024 * 1024
+}
+
+// GetLogMaxBackups returns how many rotated log files are kept
+func GetLogMaxBackups() int {
+	return v.GetInt("log.max_backups")
+}
+
+// GetHookCommand returns the command run for a hooks.on_* event, or an
+// empty string when none is configured
+func GetHookCommand(event string) string {
+	return v.GetString("hooks." + event)
+}
+
+// GetHookTimeout returns how long a hook command may run before it is killed
+func GetHookTimeout() time.Duration {
+	return v.GetDuration("hooks.timeout")
+}
+
+// GetWebhooks returns the validated notify.webhooks entries
+func GetWebhooks() ([]Webhook, error) {
+	var webhooks []Webhook
+	if err := v.UnmarshalKey("notify.webhooks", &webhooks); err != nil {
+		return nil, fmt.Errorf("invalid notify.webhooks: %v", err)
+	}
+	for i := range webhooks {
+		w := &webhooks[i]
+		if w.URL == "" {
+			return nil, fmt.Errorf("notify.webhooks entry %d has no url", i+1)
+		}
+		u, err := url.Parse(w.URL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("notify.webhooks entry %d has an invalid url", i+1)
+		}
+		if w.Name == "" {
+			w.Name = u.Scheme + "://" + u.Host
+		}
+		switch w.When {
+		case "":
+			w.When = NotifyAlways
+		case NotifyAlways, NotifyFailure:
+		default:
+			return nil, fmt.Errorf("invalid notify.webhooks when %q for %s (expected %s or %s)", w.When, w.Name, NotifyAlways, NotifyFailure)
+		}
+		if w.MinFailures <= 0 {
+			w.MinFailures = 1
+		}
+	}
+	return webhooks, nil
+}
+
+// GetNotifyRetries returns how often a failed webhook post is retried
+func GetNotifyRetries() int {
+	return v.GetInt("notify.retries")
+}
+
+// GetNotifyRetryDelay returns the wait before the first webhook retry. It
+// doubles with every further retry.
+func GetNotifyRetryDelay() time.Duration {
+	return v.GetDuration("notify.retry_delay")
+}
+
+// GetNotifyTimeout returns the timeout of a single webhook post
+func GetNotifyTimeout() time.Duration {
+	return v.GetDuration("notify.timeout")
+}
+
+// GetJobs returns the daemon.jobs entries followed by a job for every
+// source with a schedule. Each entry needs a source and a schedule, and
+// names must be unique.
+func GetJobs() ([]Job, error) {
+	var jobs []Job
+	if err := v.UnmarshalKey("daemon.jobs", &jobs); err != nil {
+		return nil, fmt.Errorf("invalid daemon.jobs: %v", err)
+	}
+	names := make(map[string]bool)
+	for i := range jobs {
+		job := &jobs[i]
+		if job.Source == "" {
+			return nil, fmt.Errorf("daemon.jobs entry %d has no source", i+1)
+		}
+		if job.Name == "" {
+			job.Name = job.Source
+		}
+		if job.Schedule == "" {
+			return nil, fmt.Errorf("daemon job %s has no schedule", job.Name)
+		}
+		if job.Profile == "" {
+			job.Profile = profile
+		} else if _, ok := v.GetStringMap("profiles")[job.Profile]; !ok {
+			return nil, fmt.Errorf("daemon job %s: no profile named %s", job.Name, job.Profile)
+		}
+		if names[job.Name] {
+			return nil, fmt.Errorf("daemon job name %s is used twice", job.Name)
+		}
+		names[job.Name] = true
+	}
+
+	// Sources with a schedule are uploaded by a job of the same name. With
+	// no profile selected, those of every profile are added as well, named
+	// profile/source.
+	profiles := []string{profile}
+	if profile == "" {
+		profiles = append(profiles, GetProfiles()...)
+	}
+	for _, p := range profiles {
+		sources, err := getSources(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range sources {
+			if s.Schedule == "" {
+				continue
+			}
+			name := s.Name
+			if p != profile {
+				name = p + "/" + s.Name
+			}
+			if names[name] {
+				return nil, fmt.Errorf("daemon job name %s is used twice", name)
+			}
+			names[name] = true
+			jobs = append(jobs, Job{
+				Name:       name,
+				Profile:    p,
+				Schedule:   s.Schedule,
+				Flags:      []string{"--source=" + s.Name},
+				QuietHours: s.QuietHours,
+			})
+		}
+	}
+	return jobs, nil
+}
+
+// GetSources returns the validated sources of the selected profile, or the
+// top-level sources without one, with absolute paths
+func GetSources() ([]Source, error) {
+	return getSources(profile)
+}
+
+func getSources(profile string) ([]Source, error) {
+	key := "sources"
+	if profile != "" {
+		key = "profiles." + profile + ".sources"
+	}
+	var sources []Source
+	if err := v.UnmarshalKey(key, &sources); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", key, err)
+	}
+	names := make(map[string]bool)
+	for i := range sources {
+		s := &sources[i]
+		if s.Name == "" {
+			return nil, fmt.Errorf("sources entry %d has no name", i+1)
+		}
+		if names[s.Name] {
+			return nil, fmt.Errorf("source name %s is used twice", s.Name)
+		}
+		names[s.Name] = true
+		if s.Path == "" {
+			return nil, fmt.Errorf("source %s has no path", s.Name)
+		}
+		path, err := filepath.Abs(s.Path)
+		if err != nil {
+			return nil, fmt.Errorf("source %s: invalid path: %v", s.Name, err)
+		}
+		s.Path = path
+
+		switch s.AlbumPolicy {
+		case "":
+			s.AlbumPolicy = AlbumPolicyNone
+			if s.Album != "" {
+				s.AlbumPolicy = AlbumPolicyFixed
+			}
+		case AlbumPolicyNone, AlbumPolicySource, AlbumPolicyFolder:
+		case AlbumPolicyFixed:
+			if s.Album == "" {
+				return nil, fmt.Errorf("source %s: album_policy %s needs an album", s.Name, AlbumPolicyFixed)
+			}
+		default:
+			return nil, fmt.Errorf("source %s: invalid album_policy %q (expected %s, %s, %s or %s)", s.Name, s.AlbumPolicy,
+				AlbumPolicyNone, AlbumPolicySource, AlbumPolicyFolder, AlbumPolicyFixed)
+		}
+
+		switch s.AfterUpload {
+		case "":
+			s.AfterUpload = AfterUploadKeep
+		case AfterUploadKeep, AfterUploadDelete:
+		case AfterUploadMove:
+			if s.MoveTo == "" {
+				return nil, fmt.Errorf("source %s: after_upload %s needs move_to", s.Name, AfterUploadMove)
+			}
+			if s.MoveTo, err = filepath.Abs(s.MoveTo); err != nil {
+				return nil, fmt.Errorf("source %s: invalid move_to: %v", s.Name, err)
+			}
+			// Moved files would be found again by the next walk
+			if s.Contains(s.MoveTo) {
+				return nil, fmt.Errorf("source %s: move_to must not be inside the source path", s.Name)
+			}
+		default:
+			return nil, fmt.Errorf("source %s: invalid after_upload %q (expected %s, %s or %s)", s.Name, s.AfterUpload,
+				AfterUploadKeep, AfterUploadDelete, AfterUploadMove)
+		}
+	}
+	return sources, nil
+}
+
+// GetSource returns the source with the given name
+func GetSource(name string) (*Source, error) {
+	sources, err := GetSources()
+	if err != nil {
+		return nil, err
+	}
+	for i := range sources {
+		if sources[i].Name == name {
+			return &sources[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no source named %s in the config", name)
+}
+
+// GetDaemonJitter returns the largest random delay added to each daemon run
+// so runs of several machines do not all start at the same moment
+func GetDaemonJitter() time.Duration {
+	return v.GetDuration("daemon.jitter")
+}
+
+// defaultMachineIDFiles are tried in order when token.machine_id_file is
+// not set
+var defaultMachineIDFiles = []string{"/etc/machine-id", "/var/lib/dbus/machine-id"}
+
+// GetTokenEncryption returns how the OAuth token is encrypted at rest
+func GetTokenEncryption() (string, error) {
+	mode := strings.ToLower(v.GetString("token.encryption"))
+	switch mode {
+	case TokenEncryptionNone, TokenEncryptionPassphrase, TokenEncryptionMachineID:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid token.encryption %q (expected %s, %s or %s)",
+		mode, TokenEncryptionNone, TokenEncryptionPassphrase, TokenEncryptionMachineID)
+}
+
+// GetTokenPassphrase returns the token passphrase from the environment, or
+// else from token.passphrase_file
+func GetTokenPassphrase() (string, error) {
+	if passphrase := os.Getenv(TokenPassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	path := v.GetString("token.passphrase_file")
+	if path == "" {
+		return "", fmt.Errorf("token.encryption is %s, but neither %s nor token.passphrase_file is set",
+			TokenEncryptionPassphrase, TokenPassphraseEnv)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token.passphrase_file: %v", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// GetMachineIDFile returns the file the machine-id key is derived from:
+// token.machine_id_file, or the first machine id file of the system
+func GetMachineIDFile() string {
+	if path := v.GetString("token.machine_id_file"); path != "" {
+		return path
+	}
+	for _, path := range defaultMachineIDFiles {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return defaultMachineIDFiles[0]
+}
+
 // EnsureDirectories creates necessary directories for credentials and database
 func EnsureDirectories() error {
 	dirs := []string{
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// maxBatchAge bounds how long a batch transaction holds the write lock, so
+// other writers such as "errors clear" or another run do not time out
+// waiting for it while files are being hashed. The age is checked on every
+// write, so the lock is held for at most about maxBatchAge plus the time
+// to process one file.
+const maxBatchAge = time.Second
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// StartBatch groups subsequent writes into transactions of up to size
+// statements, which makes large scans much faster than committing every
+// record on its own. A transaction is only opened by the first write and is
+// committed once it is full or maxBatchAge old, since it holds the write
+// lock. Reads made during the batch see its uncommitted writes. Call
+// EndBatch to commit the remainder.
+func (d *DB) StartBatch(size int) error {
+	if d.batchSize > 0 {
+		return fmt.Errorf("batch already started")
+	}
+	if size < 1 {
+		size = 1
+	}
+	d.batchSize = size
+	return nil
+}
+
+// EndBatch commits any pending writes and returns to autocommit mode
+func (d *DB) EndBatch() error {
+	d.batchSize = 0
+	if d.tx == nil {
+		return nil
+	}
+	return d.commit()
+}
+
+// conn returns the open batch transaction, or the database outside a batch
+func (d *DB) conn() queryer {
+	if d.tx != nil {
+		return d.tx
+	}
+	return d.db
+}
+
+// stmt returns a prepared statement bound to the open batch transaction
+func (d *DB) stmt(s *sql.Stmt) *sql.Stmt {
+	if d.tx == nil {
+		return s
+	}
+	if txStmt, ok := d.txStmts[s]; ok {
+		return txStmt
+	}
+	txStmt := d.tx.Stmt(s)
+	d.txStmts[s] = txStmt
+	return txStmt
+}
+
+// writeConn returns the connection for a write, opening the batch
+// transaction if a batch is started
+func (d *DB) writeConn() (queryer, error) {
+	if d.batchSize > 0 && d.tx == nil {
+		if err := d.begin(); err != nil {
+			return nil, err
+		}
+	}
+	return d.conn(), nil
+}
+
+// writeStmt returns a prepared write statement, opening the batch
+// transaction if a batch is started
+func (d *DB) writeStmt(s *sql.Stmt) (*sql.Stmt, error) {
+	if _, err := d.writeConn(); err != nil {
+		return nil, err
+	}
+	return d.stmt(s), nil
+}
+
+// exec runs a write statement and counts it towards the current batch
+func (d *DB) exec(query string, args ...any) error {
+	conn, err := d.writeConn()
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Exec(query, args...); err != nil {
+		return err
+	}
+	return d.written()
+}
+
+// written records a completed write and commits the batch once it is full
+// or old enough. The next write opens a new one.
+func (d *DB) written() error {
+	if d.tx == nil {
+		return nil
+	}
+	d.pending++
+	if d.pending < d.batchSize && time.Since(d.txStarted) < maxBatchAge {
+		return nil
+	}
+	return d.commit()
+}
+
+func (d *DB) begin() error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	d.tx = tx
+	d.txStmts = make(map[*sql.Stmt]*sql.Stmt)
+	d.txStarted = time.Now()
+	d.pending = 0
+	return nil
+}
+
+func (d *DB) commit() error {
+	tx := d.tx
+	records := d.pending
+	for _, s := range d.txStmts {
+		s.Close()
+	}
+	d.tx = nil
+	d.txStmts = nil
+	d.pending = 0
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	d.logger.Debug("Committed batch", "records", records)
+	return nil
+}
@@ -2,15 +2,37 @@ package db
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/navaneethkn/cronocam/internal/logging"
 	_ "modernc.org/sqlite"
 )
 
+// busyTimeout is how long a connection waits for another process's write
+// lock before failing with "database is locked"
+const busyTimeout = 5 * time.Second
+
 type DB struct {
 	db *sql.DB
+
+	// Prepared statements for the per-file hot paths
+	isUploadedStmt   *sql.Stmt
+	saveUploadedStmt *sql.Stmt
+
+	// Open batch transaction, see StartBatch
+	tx        *sql.Tx
+	txStmts   map[*sql.Stmt]*sql.Stmt
+	txStarted time.Time
+	batchSize int
+	pending   int
+
+	logger *slog.Logger
 }
 
 type UploadedFile struct {
@@ -31,6 +53,64 @@ type UploadError struct {
 	File    string
 	Message string
 	Time    time.Time
+
+	// Category is the uploader.ErrorCategory of the failure. It is empty for
+	// errors recorded before failures were classified.
+	Category string
+	// Attempt counts the failed attempts to upload the file so far
+	Attempt int
+	// NextRetryAt is when a normal run retries the file. Nil means the
+	// failure is permanent and the file is only retried when forced.
+	NextRetryAt *time.Time
+}
+
+// Permanent reports whether the file is left alone until a retry is forced.
+// Errors recorded before failures were classified are always retried.
+func (e *UploadError) Permanent() bool {
+	return e.Category != "" && e.NextRetryAt == nil
+}
+
+// PendingCounts summarizes the files still waiting to be uploaded
+type PendingCounts struct {
+	// Imported files are recorded without a Google Photos ID
+	Imported int64
+	// RetryDue failures are retried by the next upload
+	RetryDue int64
+	// RetryScheduled failures are waiting for their backoff to pass
+	RetryScheduled int64
+	// FailedPermanently files are only retried when forced
+	FailedPermanently int64
+}
+
+// FileMetadata holds the capture metadata extracted from a media file
+type FileMetadata struct {
+	FilePath    string
+	FileHash    string
+	TakenAt     *time.Time
+	CameraMake  string
+	CameraModel string
+	Width       int
+	Height      int
+	Latitude    *float64
+	Longitude   *float64
+	ContentID   string
+}
+
+// FilePair links two files stored as one logical photo, such as the still
+// and video of a Live Photo
+type FilePair struct {
+	Kind          string
+	PrimaryPath   string
+	CompanionPath string
+	ContentID     string
+}
+
+// PairStats summarizes the upload state of the pairs of one kind
+type PairStats struct {
+	Kind              string
+	Total             int64
+	PrimaryUploaded   int64
+	CompanionUploaded int64
 }
 
 func New(dbPath string) (*DB, error) {
@@ -39,19 +119,98 @@ func New(dbPath string) (*DB, error) {
 		return nil, err
 	}
 
-	db, err := sql.Open("sqlite", dbPath)
+	db, err := sql.Open("sqlite", dsn(dbPath))
+	if err != nil {
+		return nil, err
+	}
+
+	logger := logging.Component("db")
+	if err := initSchema(db, logger); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return openDB(db, logger)
+}
+
+// OpenReadOnly opens the database without creating, migrating or writing
+// to it, for commands such as dry runs that must leave no trace. A missing
+// database is treated as an empty one.
+func OpenReadOnly(dbPath string) (*DB, error) {
+	logger := logging.Component("db")
+	if _, err := os.Stat(dbPath); errors.Is(err, os.ErrNotExist) {
+		// Each connection would get its own in-memory database
+		db, err := sql.Open("sqlite", ":memory:")
+		if err != nil {
+			return nil, err
+		}
+		db.SetMaxOpenConns(1)
+		if err := initSchema(db, logger); err != nil {
+			db.Close()
+			return nil, err
+		}
+		return openDB(db, logger)
+	}
+
+	params := url.Values{}
+	params.Add("mode", "ro")
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()))
+	db, err := sql.Open("sqlite", "file:"+dbPath+"?"+params.Encode())
 	if err != nil {
 		return nil, err
 	}
+	for _, m := range columnMigrations {
+		exists, err := columnExists(db, m.table, m.column)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		if !exists {
+			db.Close()
+			return nil, fmt.Errorf("database schema is outdated, run a command other than a dry run to upgrade it")
+		}
+	}
+	return openDB(db, logger)
+}
 
-	if err := initSchema(db); err != nil {
+// openDB prepares the statements of an opened database
+func openDB(db *sql.DB, logger *slog.Logger) (*DB, error) {
+	d := &DB{db: db, logger: logger}
+	if err := d.prepareStatements(); err != nil {
+		db.Close()
 		return nil, err
 	}
+	return d, nil
+}
+
+// dsn builds the connection string. Every pooled connection uses WAL
+// journaling so readers such as "status" never block a running upload,
+// waits for locks instead of failing at once, and starts transactions with
+// a write lock so batches cannot deadlock on lock upgrades.
+func dsn(dbPath string) string {
+	params := url.Values{}
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()))
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "synchronous(NORMAL)")
+	params.Add("_txlock", "immediate")
+	return dbPath + "?" + params.Encode()
+}
 
-	return &DB{db: db}, nil
+// prepareStatements prepares the queries run once per scanned file
+func (d *DB) prepareStatements() error {
+	var err error
+	d.isUploadedStmt, err = d.db.Prepare("SELECT EXISTS(SELECT 1 FROM uploaded_files WHERE file_hash = ?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %v", err)
+	}
+	d.saveUploadedStmt, err = d.db.Prepare("INSERT INTO uploaded_files (file_path, file_hash, google_id) VALUES (?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %v", err)
+	}
+	return nil
 }
 
-func initSchema(db *sql.DB) error {
+func initSchema(db *sql.DB, logger *slog.Logger) error {
 	schema := `
 	CREATE TABLE IF NOT EXISTS uploaded_files (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -67,41 +226,252 @@ func initSchema(db *sql.DB) error {
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		file_path TEXT NOT NULL,
 		error_message TEXT NOT NULL,
+		category TEXT,
+		attempt INTEGER NOT NULL DEFAULT 1,
+		next_retry_at TEXT,
+		timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_upload_errors_path ON upload_errors(file_path);
+
+	CREATE TABLE IF NOT EXISTS ignored_files (
+		file_path TEXT PRIMARY KEY,
 		timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS file_metadata (
+		file_path TEXT PRIMARY KEY,
+		file_hash TEXT NOT NULL,
+		taken_at TEXT,
+		camera_make TEXT,
+		camera_model TEXT,
+		width INTEGER,
+		height INTEGER,
+		latitude REAL,
+		longitude REAL,
+		content_id TEXT,
+		timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_file_metadata_hash ON file_metadata(file_hash);
+
+	CREATE TABLE IF NOT EXISTS albums (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL UNIQUE,
+		google_id TEXT NOT NULL,
+		timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS album_items (
+		album_id TEXT NOT NULL,
+		google_id TEXT NOT NULL,
+		timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY(album_id, google_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS file_pairs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		kind TEXT NOT NULL,
+		primary_path TEXT NOT NULL,
+		companion_path TEXT NOT NULL,
+		content_id TEXT,
+		timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(primary_path, companion_path)
+	);
+
+	CREATE TABLE IF NOT EXISTS runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		command TEXT NOT NULL,
+		arguments TEXT,
+		started_at TEXT NOT NULL,
+		finished_at TEXT,
+		files_scanned INTEGER NOT NULL DEFAULT 0,
+		files_skipped INTEGER NOT NULL DEFAULT 0,
+		files_uploaded INTEGER NOT NULL DEFAULT 0,
+		files_failed INTEGER NOT NULL DEFAULT 0,
+		bytes_sent INTEGER NOT NULL DEFAULT 0,
+		exit_status INTEGER,
+		error_message TEXT
 	);`
 
-	_, err := db.Exec(schema)
-	return err
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+	return migrateSchema(db, logger)
+}
+
+// columnMigrations lists columns added to tables after they were first
+// released, so databases created by older versions can be upgraded in place
+var columnMigrations = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{"file_metadata", "content_id", "TEXT"},
+	{"upload_errors", "category", "TEXT"},
+	{"upload_errors", "attempt", "INTEGER NOT NULL DEFAULT 1"},
+	{"upload_errors", "next_retry_at", "TEXT"},
+}
+
+// migrateSchema adds any columns missing from existing tables
+func migrateSchema(db *sql.DB, logger *slog.Logger) error {
+	for _, m := range columnMigrations {
+		exists, err := columnExists(db, m.table, m.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.table, m.column, m.definition)); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %v", m.table, m.column, err)
+		}
+		logger.Info("Migrated database schema", "table", m.table, "column", m.column)
+	}
+	return nil
+}
+
+// columnExists reports whether table has a column with the given name
+func columnExists(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, typ string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &defaultValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
 }
 
 func (d *DB) Close() error {
+	if d.tx != nil {
+		if err := d.EndBatch(); err != nil {
+			d.db.Close()
+			return err
+		}
+	}
 	return d.db.Close()
 }
 
 func (d *DB) IsFileUploaded(fileHash string) (bool, error) {
 	var exists bool
-	err := d.db.QueryRow("SELECT EXISTS(SELECT 1 FROM uploaded_files WHERE file_hash = ?)", fileHash).Scan(&exists)
+	err := d.stmt(d.isUploadedStmt).QueryRow(fileHash).Scan(&exists)
 	return exists, err
 }
 
 func (d *DB) SaveUploadedFile(file *UploadedFile) error {
-	_, err := d.db.Exec(
-		"INSERT INTO uploaded_files (file_path, file_hash, google_id) VALUES (?, ?, ?)",
-		file.FilePath, file.FileHash, file.GoogleID,
-	)
-	return err
+	stmt, err := d.writeStmt(d.saveUploadedStmt)
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.Exec(file.FilePath, file.FileHash, file.GoogleID); err != nil {
+		return err
+	}
+	return d.written()
 }
 
-func (d *DB) SaveUploadError(filePath string, errorMessage string) error {
-	_, err := d.db.Exec(
-		"INSERT INTO upload_errors (file_path, error_message) VALUES (?, ?)",
-		filePath, errorMessage,
+// SaveUploadError records a failed attempt to upload a file
+func (d *DB) SaveUploadError(uploadErr *UploadError) error {
+	var category, nextRetryAt sql.NullString
+	if uploadErr.Category != "" {
+		category = sql.NullString{String: uploadErr.Category, Valid: true}
+	}
+	if uploadErr.NextRetryAt != nil {
+		nextRetryAt = sql.NullString{String: uploadErr.NextRetryAt.UTC().Format(timeLayout), Valid: true}
+	}
+	attempt := uploadErr.Attempt
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	err := d.exec(
+		"INSERT INTO upload_errors (file_path, error_message, category, attempt, next_retry_at) VALUES (?, ?, ?, ?, ?)",
+		uploadErr.File, uploadErr.Message, category, attempt, nextRetryAt,
 	)
 	return err
 }
 
+// GetLastError returns the latest error of a file that has not been
+// uploaded since, or nil if there is none
+func (d *DB) GetLastError(filePath string) (*UploadError, error) {
+	row := d.conn().QueryRow(`
+		SELECT file_path, error_message, category, attempt, next_retry_at, timestamp
+		FROM upload_errors
+		WHERE file_path = ?
+			AND file_path NOT IN (SELECT file_path FROM uploaded_files)
+		ORDER BY id DESC
+		LIMIT 1`, filePath)
+	uploadErr, err := scanUploadError(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return uploadErr, err
+}
+
+// IsFileIgnored reports whether the errors of a file are ignored
+func (d *DB) IsFileIgnored(filePath string) (bool, error) {
+	var ignored bool
+	err := d.conn().QueryRow("SELECT EXISTS(SELECT 1 FROM ignored_files WHERE file_path = ?)", filePath).Scan(&ignored)
+	return ignored, err
+}
+
+// GetDueRetries returns the files whose latest failure is scheduled to be
+// retried at or before now, along with files whose errors were recorded
+// before failures were classified
+func (d *DB) GetDueRetries(now time.Time) ([]string, error) {
+	rows, err := d.conn().Query(`
+		SELECT e.file_path
+		FROM upload_errors e
+		WHERE e.id = (SELECT MAX(id) FROM upload_errors WHERE file_path = e.file_path)
+			AND (e.category IS NULL OR e.next_retry_at <= ?)
+			AND e.file_path NOT IN (SELECT file_path FROM uploaded_files)
+			AND e.file_path NOT IN (SELECT file_path FROM ignored_files)
+		ORDER BY e.next_retry_at
+	`, now.UTC().Format(timeLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		files = append(files, path)
+	}
+	return files, rows.Err()
+}
+
+func scanUploadError(s scanner) (*UploadError, error) {
+	uploadErr := &UploadError{}
+	var category, nextRetryAt sql.NullString
+	err := s.Scan(&uploadErr.File, &uploadErr.Message, &category, &uploadErr.Attempt, &nextRetryAt, &uploadErr.Time)
+	if err != nil {
+		return nil, err
+	}
+	uploadErr.Category = category.String
+	if nextRetryAt.Valid {
+		t, err := time.Parse(timeLayout, nextRetryAt.String)
+		if err != nil {
+			return nil, err
+		}
+		uploadErr.NextRetryAt = &t
+	}
+	return uploadErr, nil
+}
+
 func (d *DB) GetUploadedFiles() ([]UploadedFile, error) {
-	rows, err := d.db.Query("SELECT id, file_path, file_hash, google_id, timestamp FROM uploaded_files")
+	rows, err := d.conn().Query("SELECT id, file_path, file_hash, google_id, timestamp FROM uploaded_files")
 	if err != nil {
 		return nil, err
 	}
@@ -123,20 +493,20 @@ func (d *DB) GetUploadStats() (*UploadStats, error) {
 	stats := &UploadStats{}
 
 	// Get total count
-	err := d.db.QueryRow("SELECT COUNT(*) FROM uploaded_files").Scan(&stats.TotalUploaded)
+	err := d.conn().QueryRow("SELECT COUNT(*) FROM uploaded_files").Scan(&stats.TotalUploaded)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get total errors
-	err = d.db.QueryRow("SELECT COUNT(*) FROM upload_errors").Scan(&stats.TotalErrors)
+	err = d.conn().QueryRow("SELECT COUNT(*) FROM upload_errors WHERE file_path NOT IN (SELECT file_path FROM ignored_files)").Scan(&stats.TotalErrors)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get last upload time
 	var lastTime sql.NullString
-	err = d.db.QueryRow("SELECT MAX(timestamp) FROM uploaded_files").Scan(&lastTime)
+	err = d.conn().QueryRow("SELECT MAX(timestamp) FROM uploaded_files").Scan(&lastTime)
 	if err != nil {
 		return nil, err
 	}
@@ -154,7 +524,7 @@ func (d *DB) GetUploadStats() (*UploadStats, error) {
 
 func (d *DB) GetPendingFiles() ([]string, error) {
 	// Get files that have been imported but not uploaded (google_id is NULL)
-	rows, err := d.db.Query("SELECT file_path FROM uploaded_files WHERE google_id IS NULL")
+	rows, err := d.conn().Query("SELECT file_path FROM uploaded_files WHERE google_id IS NULL OR google_id = ''")
 	if err != nil {
 		return nil, err
 	}
@@ -172,10 +542,11 @@ func (d *DB) GetPendingFiles() ([]string, error) {
 }
 
 func (d *DB) GetFailedFiles() ([]string, error) {
-	rows, err := d.db.Query(`
+	rows, err := d.conn().Query(`
 		SELECT DISTINCT file_path 
 		FROM upload_errors 
 		WHERE file_path NOT IN (SELECT file_path FROM uploaded_files)
+			AND file_path NOT IN (SELECT file_path FROM ignored_files)
 		ORDER BY timestamp DESC
 	`)
 	if err != nil {
@@ -195,9 +566,10 @@ func (d *DB) GetFailedFiles() ([]string, error) {
 }
 
 func (d *DB) GetRecentErrors() ([]UploadError, error) {
-	rows, err := d.db.Query(`
-		SELECT file_path, error_message, timestamp 
+	rows, err := d.conn().Query(`
+		SELECT file_path, error_message, category, attempt, next_retry_at, timestamp
 		FROM upload_errors 
+		WHERE file_path NOT IN (SELECT file_path FROM ignored_files)
 		ORDER BY timestamp DESC 
 		LIMIT 10
 	`)
@@ -208,17 +580,203 @@ func (d *DB) GetRecentErrors() ([]UploadError, error) {
 
 	var errors []UploadError
 	for rows.Next() {
-		var uploadErr UploadError
-		var timeStr string
-		if err := rows.Scan(&uploadErr.File, &uploadErr.Message, &timeStr); err != nil {
+		uploadErr, err := scanUploadError(rows)
+		if err != nil {
 			return nil, err
 		}
-		t, err := time.Parse("2006-01-02 15:04:05", timeStr)
+		errors = append(errors, *uploadErr)
+	}
+	return errors, nil
+}
+
+// GetPendingCounts counts the pending files and the failed files by their
+// retry state. Files with ignored errors are not counted.
+func (d *DB) GetPendingCounts(now time.Time) (*PendingCounts, error) {
+	counts := &PendingCounts{}
+
+	err := d.conn().QueryRow("SELECT COUNT(*) FROM uploaded_files WHERE google_id IS NULL OR google_id = ''").Scan(&counts.Imported)
+	if err != nil {
+		return nil, err
+	}
+
+	nowStr := now.UTC().Format(timeLayout)
+	err = d.conn().QueryRow(`
+		SELECT
+			COALESCE(SUM(e.category IS NULL OR e.next_retry_at <= ?), 0),
+			COALESCE(SUM(e.category IS NOT NULL AND e.next_retry_at > ?), 0),
+			COALESCE(SUM(e.category IS NOT NULL AND e.next_retry_at IS NULL), 0)
+		FROM upload_errors e
+		WHERE e.id = (SELECT MAX(id) FROM upload_errors WHERE file_path = e.file_path)
+			AND e.file_path NOT IN (SELECT file_path FROM uploaded_files)
+			AND e.file_path NOT IN (SELECT file_path FROM ignored_files)
+	`, nowStr, nowStr).Scan(&counts.RetryDue, &counts.RetryScheduled, &counts.FailedPermanently)
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// SaveFileMetadata stores the metadata for a file, replacing any earlier record
+// for the same path
+func (d *DB) SaveFileMetadata(meta *FileMetadata) error {
+	var takenAt sql.NullString
+	if meta.TakenAt != nil {
+		takenAt = sql.NullString{String: meta.TakenAt.Format(time.RFC3339), Valid: true}
+	}
+	var latitude, longitude sql.NullFloat64
+	if meta.Latitude != nil && meta.Longitude != nil {
+		latitude = sql.NullFloat64{Float64: *meta.Latitude, Valid: true}
+		longitude = sql.NullFloat64{Float64: *meta.Longitude, Valid: true}
+	}
+
+	err := d.exec(`
+		INSERT INTO file_metadata (file_path, file_hash, taken_at, camera_make, camera_model, width, height, latitude, longitude, content_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(file_path) DO UPDATE SET
+			file_hash = excluded.file_hash,
+			taken_at = excluded.taken_at,
+			camera_make = excluded.camera_make,
+			camera_model = excluded.camera_model,
+			width = excluded.width,
+			height = excluded.height,
+			latitude = excluded.latitude,
+			longitude = excluded.longitude,
+			content_id = excluded.content_id,
+			timestamp = CURRENT_TIMESTAMP`,
+		meta.FilePath, meta.FileHash, takenAt, meta.CameraMake, meta.CameraModel,
+		meta.Width, meta.Height, latitude, longitude, meta.ContentID,
+	)
+	return err
+}
+
+// GetFileMetadata returns the stored metadata for a file path, or nil if the
+// file has not been scanned yet
+func (d *DB) GetFileMetadata(filePath string) (*FileMetadata, error) {
+	meta := &FileMetadata{}
+	var takenAt sql.NullString
+	var cameraMake, cameraModel, contentID sql.NullString
+	var width, height sql.NullInt64
+	var latitude, longitude sql.NullFloat64
+
+	err := d.conn().QueryRow(`
+		SELECT file_path, file_hash, taken_at, camera_make, camera_model, width, height, latitude, longitude, content_id
+		FROM file_metadata
+		WHERE file_path = ?`, filePath,
+	).Scan(&meta.FilePath, &meta.FileHash, &takenAt, &cameraMake, &cameraModel, &width, &height, &latitude, &longitude, &contentID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if takenAt.Valid {
+		t, err := time.Parse(time.RFC3339, takenAt.String)
 		if err != nil {
 			return nil, err
 		}
-		uploadErr.Time = t
-		errors = append(errors, uploadErr)
+		meta.TakenAt = &t
 	}
-	return errors, nil
+	meta.CameraMake = cameraMake.String
+	meta.CameraModel = cameraModel.String
+	meta.ContentID = contentID.String
+	meta.Width = int(width.Int64)
+	meta.Height = int(height.Int64)
+	if latitude.Valid && longitude.Valid {
+		meta.Latitude = &latitude.Float64
+		meta.Longitude = &longitude.Float64
+	}
+
+	return meta, nil
+}
+
+// GetAlbumID returns the Google Photos ID of an album created by CronoCam,
+// or an empty string if no album with that title has been created
+func (d *DB) GetAlbumID(title string) (string, error) {
+	var googleID string
+	err := d.conn().QueryRow("SELECT google_id FROM albums WHERE title = ?", title).Scan(&googleID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return googleID, err
+}
+
+// SaveAlbum records an album created in Google Photos
+func (d *DB) SaveAlbum(title, googleID string) error {
+	err := d.exec(
+		"INSERT INTO albums (title, google_id) VALUES (?, ?)",
+		title, googleID,
+	)
+	return err
+}
+
+// GetGoogleID returns the Google Photos ID of the uploaded file with the
+// given hash, or an empty string if it was not uploaded by CronoCam
+func (d *DB) GetGoogleID(fileHash string) (string, error) {
+	var googleID sql.NullString
+	err := d.conn().QueryRow("SELECT google_id FROM uploaded_files WHERE file_hash = ?", fileHash).Scan(&googleID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return googleID.String, err
+}
+
+// IsInAlbum reports whether a media item was added to an album
+func (d *DB) IsInAlbum(albumID, googleID string) (bool, error) {
+	var exists bool
+	err := d.conn().QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM album_items WHERE album_id = ? AND google_id = ?)",
+		albumID, googleID,
+	).Scan(&exists)
+	return exists, err
+}
+
+// SaveAlbumItem records that a media item was added to an album
+func (d *DB) SaveAlbumItem(albumID, googleID string) error {
+	return d.exec(
+		"INSERT OR IGNORE INTO album_items (album_id, google_id) VALUES (?, ?)",
+		albumID, googleID,
+	)
+}
+
+// SaveFilePair records that two files form one logical photo
+func (d *DB) SaveFilePair(pair *FilePair) error {
+	err := d.exec(`
+		INSERT INTO file_pairs (kind, primary_path, companion_path, content_id)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(primary_path, companion_path) DO UPDATE SET
+			kind = excluded.kind,
+			content_id = excluded.content_id`,
+		pair.Kind, pair.PrimaryPath, pair.CompanionPath, pair.ContentID,
+	)
+	return err
+}
+
+// GetPairStats returns how many pairs of each kind exist and how many of
+// their halves have been uploaded
+func (d *DB) GetPairStats() ([]PairStats, error) {
+	rows, err := d.conn().Query(`
+		SELECT
+			p.kind,
+			COUNT(*),
+			SUM(EXISTS(SELECT 1 FROM uploaded_files u WHERE u.file_path = p.primary_path)),
+			SUM(EXISTS(SELECT 1 FROM uploaded_files u WHERE u.file_path = p.companion_path))
+		FROM file_pairs p
+		GROUP BY p.kind
+		ORDER BY p.kind
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []PairStats
+	for rows.Next() {
+		var s PairStats
+		if err := rows.Scan(&s.Kind, &s.Total, &s.PrimaryUploaded, &s.CompanionUploaded); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
 }
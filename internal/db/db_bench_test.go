@@ -0,0 +1,95 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// benchRecords is the size of the library simulated by the benchmarks
+const benchRecords = 100000
+
+func openBenchDB(b *testing.B) *DB {
+	b.Helper()
+	d, err := New(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatalf("failed to open database: %v", err)
+	}
+	return d
+}
+
+func benchFile(i int) *UploadedFile {
+	return &UploadedFile{
+		FilePath: fmt.Sprintf("/photos/%03d/IMG_%06d.JPG", i/1000, i),
+		FileHash: fmt.Sprintf("%064x", i),
+		GoogleID: fmt.Sprintf("google-%d", i),
+	}
+}
+
+func insertRecords(b *testing.B, d *DB, batchSize int) {
+	b.Helper()
+	if batchSize > 0 {
+		if err := d.StartBatch(batchSize); err != nil {
+			b.Fatal(err)
+		}
+	}
+	for i := 0; i < benchRecords; i++ {
+		if err := d.SaveUploadedFile(benchFile(i)); err != nil {
+			b.Fatalf("failed to save record %d: %v", i, err)
+		}
+	}
+	if err := d.EndBatch(); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// BenchmarkSaveUploadedFile100k measures inserting 100k upload records, one
+// transaction per record and in batches as used by import
+func BenchmarkSaveUploadedFile100k(b *testing.B) {
+	for _, batchSize := range []int{0, 100, 500, 5000} {
+		name := "autocommit"
+		if batchSize > 0 {
+			name = fmt.Sprintf("batch=%d", batchSize)
+		}
+
+		b.Run(name, func(b *testing.B) {
+			var elapsed time.Duration
+			for n := 0; n < b.N; n++ {
+				b.StopTimer()
+				d := openBenchDB(b)
+				b.StartTimer()
+
+				start := time.Now()
+				insertRecords(b, d, batchSize)
+				elapsed += time.Since(start)
+
+				b.StopTimer()
+				d.Close()
+				b.StartTimer()
+			}
+			b.ReportMetric(float64(benchRecords*b.N)/elapsed.Seconds(), "records/s")
+		})
+	}
+}
+
+// BenchmarkIsFileUploaded100k measures duplicate lookups against a database
+// holding 100k records
+func BenchmarkIsFileUploaded100k(b *testing.B) {
+	d := openBenchDB(b)
+	defer d.Close()
+	insertRecords(b, d, 5000)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		// Alternate hits and misses
+		uploaded, err := d.IsFileUploaded(fmt.Sprintf("%064x", n%(2*benchRecords)))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if uploaded != (n%(2*benchRecords) < benchRecords) {
+			b.Fatalf("unexpected result for record %d", n)
+		}
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "lookups/s")
+}
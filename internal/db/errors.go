@@ -0,0 +1,94 @@
+package db
+
+import (
+	"strings"
+	"time"
+)
+
+// ErrorFilter selects upload errors. Zero fields match everything.
+type ErrorFilter struct {
+	// Since keeps errors recorded at or after this time
+	Since *time.Time
+	// Path keeps errors of this file or of files below this directory
+	Path string
+	// Category keeps errors of one uploader.ErrorCategory
+	Category string
+	// Limit caps the number of errors returned
+	Limit int
+}
+
+// where builds the SQL condition for the filter. Errors of ignored files
+// never match.
+func (f ErrorFilter) where() (string, []any) {
+	conditions := []string{"file_path NOT IN (SELECT file_path FROM ignored_files)"}
+	var args []any
+
+	if f.Since != nil {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, f.Since.UTC().Format(timeLayout))
+	}
+	if f.Path != "" {
+		dir := strings.TrimSuffix(f.Path, "/") + "/"
+		conditions = append(conditions, "(file_path = ? OR substr(file_path, 1, length(?)) = ?)")
+		args = append(args, f.Path, dir, dir)
+	}
+	if f.Category != "" {
+		conditions = append(conditions, "category = ?")
+		args = append(args, f.Category)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// GetErrors returns the errors matching the filter, newest first
+func (d *DB) GetErrors(f ErrorFilter) ([]UploadError, error) {
+	where, args := f.where()
+	query := `
+		SELECT file_path, error_message, category, attempt, next_retry_at, timestamp
+		FROM upload_errors
+		WHERE ` + where + `
+		ORDER BY id DESC`
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+
+	rows, err := d.conn().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var errors []UploadError
+	for rows.Next() {
+		uploadErr, err := scanUploadError(rows)
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, *uploadErr)
+	}
+	return errors, rows.Err()
+}
+
+// DeleteErrors removes the errors matching the filter and returns how many
+// were removed. The limit of the filter is not applied.
+func (d *DB) DeleteErrors(f ErrorFilter) (int64, error) {
+	where, args := f.where()
+	conn, err := d.writeConn()
+	if err != nil {
+		return 0, err
+	}
+	result, err := conn.Exec("DELETE FROM upload_errors WHERE "+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	if err := d.written(); err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// IgnoreFile hides the errors of a file from reports and stops it from
+// being retried
+func (d *DB) IgnoreFile(filePath string) error {
+	return d.exec("INSERT OR IGNORE INTO ignored_files (file_path) VALUES (?)", filePath)
+}
@@ -0,0 +1,170 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// timeLayout matches the format SQLite uses for CURRENT_TIMESTAMP
+const timeLayout = "2006-01-02 15:04:05"
+
+// RunStats counts what a single upload or import run did
+type RunStats struct {
+	FilesScanned  int64
+	FilesSkipped  int64
+	FilesUploaded int64
+	FilesFailed   int64
+	BytesSent     int64
+}
+
+// Run records one invocation of a command
+type Run struct {
+	ID         int64
+	Command    string
+	Arguments  string
+	StartedAt  time.Time
+	FinishedAt *time.Time
+	RunStats
+	// ExitStatus is nil while the run is in progress or if it never finished
+	ExitStatus *int
+	Error      string
+}
+
+// Duration returns how long the run took, or zero if it has not finished
+func (r *Run) Duration() time.Duration {
+	if r.FinishedAt == nil {
+		return 0
+	}
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+const runColumns = `id, command, arguments, started_at, finished_at, files_scanned, files_skipped,
+	files_uploaded, files_failed, bytes_sent, exit_status, error_message`
+
+// StartRun records the start of a command and returns the new run
+func (d *DB) StartRun(command, arguments string) (*Run, error) {
+	run := &Run{
+		Command:   command,
+		Arguments: arguments,
+		StartedAt: time.Now().UTC().Truncate(time.Second),
+	}
+
+	conn, err := d.writeConn()
+	if err != nil {
+		return nil, err
+	}
+	result, err := conn.Exec(
+		"INSERT INTO runs (command, arguments, started_at) VALUES (?, ?, ?)",
+		run.Command, run.Arguments, run.StartedAt.Format(timeLayout),
+	)
+	if err != nil {
+		return nil, err
+	}
+	run.ID, err = result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return run, d.written()
+}
+
+// FinishRun stores the final statistics and exit status of a run
+func (d *DB) FinishRun(run *Run) error {
+	finishedAt := time.Now().UTC().Truncate(time.Second)
+	run.FinishedAt = &finishedAt
+
+	var errorMessage sql.NullString
+	if run.Error != "" {
+		errorMessage = sql.NullString{String: run.Error, Valid: true}
+	}
+
+	return d.exec(`
+		UPDATE runs SET
+			finished_at = ?,
+			files_scanned = ?,
+			files_skipped = ?,
+			files_uploaded = ?,
+			files_failed = ?,
+			bytes_sent = ?,
+			exit_status = ?,
+			error_message = ?
+		WHERE id = ?`,
+		finishedAt.Format(timeLayout), run.FilesScanned, run.FilesSkipped, run.FilesUploaded,
+		run.FilesFailed, run.BytesSent, run.ExitStatus, errorMessage, run.ID,
+	)
+}
+
+// GetRuns returns the most recent runs, newest first
+func (d *DB) GetRuns(limit int) ([]Run, error) {
+	rows, err := d.conn().Query("SELECT "+runColumns+" FROM runs ORDER BY id DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+	return runs, rows.Err()
+}
+
+// GetRun returns a single run, or nil if no run has that ID
+func (d *DB) GetRun(id int64) (*Run, error) {
+	run, err := scanRun(d.conn().QueryRow("SELECT "+runColumns+" FROM runs WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return run, err
+}
+
+// GetLastSuccessfulRun returns the newest run that exited successfully, or
+// nil if there is none
+func (d *DB) GetLastSuccessfulRun() (*Run, error) {
+	run, err := scanRun(d.conn().QueryRow("SELECT " + runColumns + " FROM runs WHERE exit_status = 0 ORDER BY id DESC LIMIT 1"))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return run, err
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRun(s scanner) (*Run, error) {
+	run := &Run{}
+	var arguments, finishedAt, errorMessage sql.NullString
+	var startedAt string
+	var exitStatus sql.NullInt64
+
+	err := s.Scan(&run.ID, &run.Command, &arguments, &startedAt, &finishedAt,
+		&run.FilesScanned, &run.FilesSkipped, &run.FilesUploaded, &run.FilesFailed,
+		&run.BytesSent, &exitStatus, &errorMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	run.StartedAt, err = time.Parse(timeLayout, startedAt)
+	if err != nil {
+		return nil, err
+	}
+	if finishedAt.Valid {
+		t, err := time.Parse(timeLayout, finishedAt.String)
+		if err != nil {
+			return nil, err
+		}
+		run.FinishedAt = &t
+	}
+	if exitStatus.Valid {
+		status := int(exitStatus.Int64)
+		run.ExitStatus = &status
+	}
+	run.Arguments = arguments.String
+	run.Error = errorMessage.String
+	return run, nil
+}
@@ -0,0 +1,193 @@
+package filter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/navaneethkn/cronocam/internal/config"
+	"github.com/navaneethkn/cronocam/internal/metadata"
+)
+
+// Media types accepted by the type filter
+const (
+	TypeImage = "image"
+	TypeVideo = "video"
+)
+
+// Options describes which files should be processed. Zero values disable
+// the corresponding check.
+type Options struct {
+	TakenAfter  *time.Time // inclusive
+	TakenBefore *time.Time // exclusive
+	Camera      string
+	MinSize     int64
+	MaxSize     int64
+	MediaType   string
+	// Include and Exclude are glob patterns. A pattern with a slash is
+	// matched against the path relative to Root, one without against the
+	// file name and the names of the folders below Root. When Include is
+	// set a file must match one of its patterns.
+	Root    string
+	Include []string
+	Exclude []string
+}
+
+// Filter decides whether a scanned file should be processed
+type Filter struct {
+	opts   Options
+	images map[string]bool
+	videos map[string]bool
+}
+
+// New validates the options and creates a filter
+func New(opts Options) (*Filter, error) {
+	switch opts.MediaType {
+	case "", TypeImage, TypeVideo:
+	default:
+		return nil, fmt.Errorf("invalid media type %q (expected %s or %s)", opts.MediaType, TypeImage, TypeVideo)
+	}
+	if opts.MinSize < 0 || opts.MaxSize < 0 {
+		return nil, fmt.Errorf("file size limits must not be negative")
+	}
+	if opts.MaxSize > 0 && opts.MinSize > opts.MaxSize {
+		return nil, fmt.Errorf("minimum size %d is larger than maximum size %d", opts.MinSize, opts.MaxSize)
+	}
+	if opts.TakenAfter != nil && opts.TakenBefore != nil && !opts.TakenAfter.Before(*opts.TakenBefore) {
+		return nil, fmt.Errorf("taken-after must be earlier than taken-before")
+	}
+	for _, pattern := range append(append([]string(nil), opts.Include...), opts.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+	}
+
+	// RAW files count as images
+	images := config.GetSupportedImages()
+	for ext := range config.GetSupportedRaw() {
+		images[ext] = true
+	}
+
+	return &Filter{
+		opts:   opts,
+		images: images,
+		videos: config.GetSupportedVideos(),
+	}, nil
+}
+
+// NeedsMetadata reports whether MatchMetadata has any checks to perform
+func (f *Filter) NeedsMetadata() bool {
+	return f != nil && (f.opts.TakenAfter != nil || f.opts.TakenBefore != nil || f.opts.Camera != "")
+}
+
+// MatchFile applies the checks that only need the file name and stat
+// information, so they can run before the file is opened
+func (f *Filter) MatchFile(path string, info os.FileInfo) (bool, string) {
+	if f == nil {
+		return true, ""
+	}
+
+	if f.opts.MinSize > 0 && info.Size() < f.opts.MinSize {
+		return false, fmt.Sprintf("smaller than %d bytes", f.opts.MinSize)
+	}
+	if f.opts.MaxSize > 0 && info.Size() > f.opts.MaxSize {
+		return false, fmt.Sprintf("larger than %d bytes", f.opts.MaxSize)
+	}
+
+	if len(f.opts.Include) > 0 || len(f.opts.Exclude) > 0 {
+		if pattern := f.matchPattern(f.opts.Exclude, path, true); pattern != "" {
+			return false, fmt.Sprintf("excluded by %q", pattern)
+		}
+		if len(f.opts.Include) > 0 && f.matchPattern(f.opts.Include, path, false) == "" {
+			return false, "not included by any pattern"
+		}
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	switch f.opts.MediaType {
+	case TypeImage:
+		if !f.images[ext] {
+			return false, "not an image"
+		}
+	case TypeVideo:
+		if !f.videos[ext] {
+			return false, "not a video"
+		}
+	}
+
+	return true, ""
+}
+
+// matchPattern returns the first pattern matching path, or an empty string.
+// Patterns without a slash match the file name, and with folders the name
+// of any folder between Root and the file.
+func (f *Filter) matchPattern(patterns []string, path string, folders bool) string {
+	rel := path
+	if f.opts.Root != "" {
+		if r, err := filepath.Rel(f.opts.Root, path); err == nil {
+			rel = r
+		}
+	}
+	rel = filepath.ToSlash(rel)
+	names := strings.Split(rel, "/")
+	if !folders {
+		names = names[len(names)-1:]
+	}
+
+	for _, pattern := range patterns {
+		if strings.Contains(pattern, "/") {
+			if ok, _ := filepath.Match(pattern, rel); ok {
+				return pattern
+			}
+			continue
+		}
+		for _, name := range names {
+			if ok, _ := filepath.Match(pattern, name); ok {
+				return pattern
+			}
+		}
+	}
+	return ""
+}
+
+// MatchMetadata applies the capture date and camera checks. The capture time
+// comes from the file metadata when present, otherwise from the mtime.
+func (f *Filter) MatchMetadata(meta *metadata.Metadata, info os.FileInfo) (bool, string) {
+	if !f.NeedsMetadata() {
+		return true, ""
+	}
+
+	taken := meta.CaptureTime(info.ModTime())
+	if f.opts.TakenAfter != nil && taken.Before(*f.opts.TakenAfter) {
+		return false, fmt.Sprintf("taken %s, before %s", taken.Format(time.DateOnly), f.opts.TakenAfter.Format(time.DateOnly))
+	}
+	if f.opts.TakenBefore != nil && !taken.Before(*f.opts.TakenBefore) {
+		return false, fmt.Sprintf("taken %s, not before %s", taken.Format(time.DateOnly), f.opts.TakenBefore.Format(time.DateOnly))
+	}
+
+	if f.opts.Camera != "" {
+		camera := ""
+		if meta != nil {
+			camera = strings.TrimSpace(meta.CameraMake + " " + meta.CameraModel)
+		}
+		if !strings.Contains(strings.ToLower(camera), strings.ToLower(f.opts.Camera)) {
+			return false, fmt.Sprintf("camera %q does not match %q", camera, f.opts.Camera)
+		}
+	}
+
+	return true, ""
+}
+
+// ParseDate parses a date given on the command line. Both plain dates
+// (interpreted as midnight local time) and RFC 3339 timestamps are accepted.
+func ParseDate(value string) (*time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return &t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	return nil, fmt.Errorf("invalid date %q (expected YYYY-MM-DD or RFC 3339)", value)
+}
@@ -0,0 +1,93 @@
+// Package hooks runs the user commands configured for upload events
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Events a hook can be configured for
+const (
+	EventUpload      = "on_upload"
+	EventFailure     = "on_failure"
+	EventRunComplete = "on_run_complete"
+)
+
+// maxOutput limits how much of a hook's output is kept for the log
+const maxOutput = 4096
+
+// Error describes a hook that failed, timed out or could not be started
+type Error struct {
+	Event  string
+	Err    error
+	Output string
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("hook %s failed: %v", e.Event, e.Err)
+	if e.Output != "" {
+		msg += ", output: " + e.Output
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Run runs command through the shell with payload as JSON on stdin and env
+// added to the environment. The command is killed once timeout passes. The
+// combined output is returned for logging.
+func Run(ctx context.Context, event, command string, timeout time.Duration, payload any, env map[string]string) (string, error) {
+	input, err := json.Marshal(payload)
+	if err != nil {
+		return "", &Error{Event: event, Err: fmt.Errorf("failed to encode payload: %v", err)}
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd := shellCommand(ctx, command)
+	cmd.Stdin = bytes.NewReader(input)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// Do not wait forever for pipes held open by children of the hook
+	cmd.WaitDelay = time.Second
+
+	cmd.Env = os.Environ()
+	cmd.Env = append(cmd.Env, "CRONOCAM_EVENT="+event)
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+
+	err = cmd.Run()
+	out := strings.TrimSpace(output.String())
+	if len(out) > maxOutput {
+		out = out[:maxOutput] + "..."
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return out, &Error{Event: event, Err: fmt.Errorf("timed out after %s", timeout), Output: out}
+	}
+	if err != nil {
+		return out, &Error{Event: event, Err: err, Output: out}
+	}
+	return out, nil
+}
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "/bin/sh", "-c", command)
+}
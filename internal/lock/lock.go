@@ -0,0 +1,212 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// invalidLockAge is how long an unreadable lock file is respected before it
+// is treated as stale. Locks are created complete, so one that cannot be
+// parsed was left by a crash or an older version.
+const invalidLockAge = 5 * time.Second
+
+// Info describes the process holding a lock
+type Info struct {
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	Command   string    `json:"command"`
+	StartedAt time.Time `json:"started_at"`
+	// Invalid is set when the lock file could not be parsed; StartedAt is
+	// then its modification time
+	Invalid bool `json:"-"`
+}
+
+// String formats the holder for log and status output
+func (i *Info) String() string {
+	if i.Invalid {
+		return fmt.Sprintf("unreadable lock file (written %s)", i.StartedAt.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("%s (PID %d on %s, started %s)", i.Command, i.PID, i.Host, i.StartedAt.Format(time.RFC3339))
+}
+
+// LockedError is returned when another live process holds the lock
+type LockedError struct {
+	Holder *Info
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("another run is in progress: %s", e.Holder)
+}
+
+// Lock is an exclusive lock backed by a file containing the holder's details
+type Lock struct {
+	path string
+	info Info
+}
+
+// Acquire takes the lock at path for command. A lock left behind by a
+// process that no longer runs on this host is removed and taken over.
+func Acquire(path, command string) (*Lock, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hostname: %v", err)
+	}
+
+	info := Info{
+		PID:       os.Getpid(),
+		Host:      host,
+		Command:   command,
+		StartedAt: time.Now(),
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	// The lock is written to a temporary file and linked into place, so it
+	// never exists without its contents
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lock file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, werr := tmp.Write(data)
+	cerr := tmp.Close()
+	if werr != nil || cerr != nil {
+		return nil, fmt.Errorf("failed to write lock file: %v", errors.Join(werr, cerr))
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write lock file: %v", err)
+	}
+
+	// Two attempts: the second follows removal of a stale lock
+	for attempt := 0; attempt < 2; attempt++ {
+		err := os.Link(tmp.Name(), path)
+		if err == nil {
+			return &Lock{path: path, info: info}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file: %v", err)
+		}
+
+		holder, err := Read(path)
+		if err != nil {
+			return nil, err
+		}
+		if holder == nil {
+			// Released between our create and read
+			continue
+		}
+		if !IsStale(holder) {
+			return nil, &LockedError{Holder: holder}
+		}
+		if err := removeStale(path, holder); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("failed to acquire lock %s", path)
+}
+
+// Wait blocks until the lock is acquired or ctx is cancelled, polling at the
+// given interval. onWait is called once with the holder when the lock is busy.
+func Wait(ctx context.Context, path, command string, interval time.Duration, onWait func(*Info)) (*Lock, error) {
+	notified := false
+	for {
+		l, err := Acquire(path, command)
+		if err == nil {
+			return l, nil
+		}
+
+		var locked *LockedError
+		if !errors.As(err, &locked) {
+			return nil, err
+		}
+		if !notified && onWait != nil {
+			onWait(locked.Holder)
+			notified = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Release removes the lock file if it still belongs to this lock
+func (l *Lock) Release() error {
+	holder, err := Read(l.path)
+	if err != nil {
+		return err
+	}
+	if holder == nil || holder.PID != l.info.PID || holder.Host != l.info.Host {
+		return nil
+	}
+	return os.Remove(l.path)
+}
+
+// Read returns the current holder of the lock at path, or nil if the lock is
+// free. A lock file that cannot be parsed is returned as an Invalid holder.
+func Read(path string) (*Info, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file: %v", err)
+	}
+
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		stat, statErr := os.Stat(path)
+		if os.IsNotExist(statErr) {
+			return nil, nil
+		}
+		if statErr != nil {
+			return nil, fmt.Errorf("failed to read lock file: %v", statErr)
+		}
+		return &Info{Invalid: true, StartedAt: stat.ModTime()}, nil
+	}
+	return &info, nil
+}
+
+// IsStale reports whether the holder is a process on this host that has
+// exited. Locks held from other hosts are never considered stale since their
+// processes cannot be checked. Unreadable locks are stale once they are
+// older than a few seconds.
+func IsStale(holder *Info) bool {
+	if holder.Invalid {
+		return time.Since(holder.StartedAt) > invalidLockAge
+	}
+	host, err := os.Hostname()
+	if err != nil || holder.Host != host {
+		return false
+	}
+	return !processRunning(holder.PID)
+}
+
+// removeStale deletes a stale lock file, unless another process replaced it
+// after it was read
+func removeStale(path string, stale *Info) error {
+	current, err := Read(path)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return nil
+	}
+	if current.Invalid != stale.Invalid || current.PID != stale.PID || current.Host != stale.Host || !current.StartedAt.Equal(stale.StartedAt) {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale lock: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package lock
+
+import "os"
+
+// processRunning reports whether a process with the given PID exists.
+// FindProcess opens a handle on Windows and fails for unknown PIDs.
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
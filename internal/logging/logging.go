@@ -0,0 +1,116 @@
+// Package logging sets up the structured logger shared by all commands
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Log formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Options configures the logger
+type Options struct {
+	// Level is one of debug, info, warn or error
+	Level string
+	// Format is FormatText or FormatJSON
+	Format string
+	// File receives the log instead of stderr when set
+	File string
+	// MaxSize is the size in bytes at which File is rotated, 0 to never rotate
+	MaxSize int64
+	// MaxBackups is the number of rotated files kept
+	MaxBackups int
+}
+
+// ParseLevel parses a level name
+func ParseLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.ToLower(level))); err != nil {
+		return 0, fmt.Errorf("invalid log level %q (expected debug, info, warn or error)", level)
+	}
+	return l, nil
+}
+
+// Setup builds a logger from opts and installs it as the default logger, so
+// the standard log package writes through it as well. The returned closer
+// closes the log file.
+func Setup(opts Options) (io.Closer, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	var w io.WriteCloser = nopCloser{console}
+	if opts.File != "" {
+		f, err := OpenRotatingFile(opts.File, opts.MaxSize, opts.MaxBackups)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %v", err)
+		}
+		w = f
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch opts.Format {
+	case FormatText, "":
+		handler = slog.NewTextHandler(w, handlerOpts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	default:
+		w.Close()
+		return nil, fmt.Errorf("invalid log format %q (expected text or json)", opts.Format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return w, nil
+}
+
+// Component returns the default logger tagged with the name of a package
+func Component(name string) *slog.Logger {
+	return slog.Default().With("component", name)
+}
+
+// console receives the log when no log file is set
+var console = &consoleWriter{w: os.Stderr}
+
+type consoleWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (c *consoleWriter) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	w := c.w
+	c.mu.Unlock()
+	return w.Write(p)
+}
+
+// RedirectConsole sends log records meant for stderr to w until the returned
+// function is called. It lets a progress display keep its status line below
+// the log.
+func RedirectConsole(w io.Writer) (restore func()) {
+	console.mu.Lock()
+	prev := console.w
+	console.w = w
+	console.mu.Unlock()
+
+	return func() {
+		console.mu.Lock()
+		console.w = prev
+		console.mu.Unlock()
+	}
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,110 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is a log file that is renamed to file.1 once it reaches its
+// maximum size. Older backups shift to file.2, file.3 and so on, and the
+// oldest is removed once there are more than the configured number.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenRotatingFile opens path for appending
+func OpenRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file = f
+	r.size = info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if p would take the file past its
+// maximum size
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// A failed rotation may have left the file closed
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		// When the file could be reopened, keep appending to it rather than
+		// dropping lines; the next write tries to rotate again
+		if err := r.rotate(); err != nil && r.file == nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate moves the file to its first backup and starts a new one. The path
+// is reopened for appending even when closing or renaming fails.
+func (r *RotatingFile) rotate() error {
+	err := r.file.Close()
+	r.file = nil
+	if err == nil {
+		err = r.shift()
+	}
+	if openErr := r.open(); openErr != nil {
+		return openErr
+	}
+	return err
+}
+
+// shift renames the backups up by one and the file to the first backup, or
+// removes the file when no backups are kept
+func (r *RotatingFile) shift() error {
+	if r.maxBackups > 0 {
+		os.Remove(r.backup(r.maxBackups))
+		for i := r.maxBackups - 1; i >= 1; i-- {
+			os.Rename(r.backup(i), r.backup(i+1))
+		}
+		return os.Rename(r.path, r.backup(1))
+	}
+	return os.Remove(r.path)
+}
+
+func (r *RotatingFile) backup(i int) string {
+	return fmt.Sprintf("%s.%d", r.path, i)
+}
+
+// Close closes the file
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Close()
+}
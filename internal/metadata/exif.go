@@ -0,0 +1,331 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// EXIF tag IDs used by the extractor
+const (
+	tagImageWidth         = 0x0100
+	tagImageLength        = 0x0101
+	tagMake               = 0x010F
+	tagModel              = 0x0110
+	tagDateTime           = 0x0132
+	tagExifIFD            = 0x8769
+	tagGPSIFD             = 0x8825
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+	tagMakerNote          = 0x927C
+	tagPixelXDimension    = 0xA002
+	tagPixelYDimension    = 0xA003
+
+	tagGPSLatitudeRef  = 0x0001
+	tagGPSLatitude     = 0x0002
+	tagGPSLongitudeRef = 0x0003
+	tagGPSLongitude    = 0x0004
+
+	// appleContentIdentifier is the Live Photo asset identifier in Apple's
+	// maker note
+	appleContentIdentifier = 0x0011
+)
+
+// appleMakerNoteHeader starts the maker note written by iOS devices. Its IFD
+// follows at offset 14 and is always big-endian.
+var appleMakerNoteHeader = []byte("Apple iOS\x00")
+
+// EXIF field types
+const (
+	typeByte      = 1
+	typeASCII     = 2
+	typeShort     = 3
+	typeLong      = 4
+	typeRational  = 5
+	typeUndefined = 7
+	typeSLong     = 9
+	typeSRational = 10
+)
+
+var typeSizes = map[uint16]int{
+	typeByte:      1,
+	typeASCII:     1,
+	typeShort:     2,
+	typeLong:      4,
+	typeRational:  8,
+	typeUndefined: 1,
+	typeSLong:     4,
+	typeSRational: 8,
+}
+
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// maxTIFFSize caps how much of a bare TIFF file is read for its IFDs
+const maxTIFFSize = 4 * 1024 * 1024
+
+type ifdEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte
+}
+
+type tiffReader struct {
+	buf   []byte
+	order binary.ByteOrder
+}
+
+// parseTIFF decodes the TIFF structure embedded in an EXIF block
+func parseTIFF(buf []byte) (*Metadata, error) {
+	if len(buf) < 8 {
+		return nil, fmt.Errorf("exif data too short")
+	}
+
+	t := &tiffReader{buf: buf}
+	switch string(buf[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("invalid TIFF byte order")
+	}
+	if t.order.Uint16(buf[2:4]) != 42 {
+		return nil, fmt.Errorf("invalid TIFF magic number")
+	}
+
+	ifd0, err := t.readIFD(t.order.Uint32(buf[4:8]))
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Metadata{
+		CameraMake:  t.stringValue(ifd0[tagMake]),
+		CameraModel: t.stringValue(ifd0[tagModel]),
+		Width:       int(t.uintValue(ifd0[tagImageWidth])),
+		Height:      int(t.uintValue(ifd0[tagImageLength])),
+	}
+	dateTime := t.stringValue(ifd0[tagDateTime])
+
+	if e, ok := ifd0[tagExifIFD]; ok {
+		exif, err := t.readIFD(t.uintValue(e))
+		if err == nil {
+			if original := t.stringValue(exif[tagDateTimeOriginal]); original != "" {
+				dateTime = original
+			}
+			m.TakenAt = parseExifTime(dateTime, t.stringValue(exif[tagOffsetTimeOriginal]))
+			if w := t.uintValue(exif[tagPixelXDimension]); w > 0 {
+				m.Width = int(w)
+			}
+			if h := t.uintValue(exif[tagPixelYDimension]); h > 0 {
+				m.Height = int(h)
+			}
+			m.ContentID = appleContentID(exif[tagMakerNote].data)
+		}
+	}
+	if m.TakenAt == nil {
+		m.TakenAt = parseExifTime(dateTime, "")
+	}
+
+	if e, ok := ifd0[tagGPSIFD]; ok {
+		gps, err := t.readIFD(t.uintValue(e))
+		if err == nil {
+			m.Latitude = t.coordinate(gps[tagGPSLatitude], t.stringValue(gps[tagGPSLatitudeRef]), "S")
+			m.Longitude = t.coordinate(gps[tagGPSLongitude], t.stringValue(gps[tagGPSLongitudeRef]), "W")
+		}
+	}
+
+	return m, nil
+}
+
+// readIFD reads the entries of the image file directory at offset
+func (t *tiffReader) readIFD(offset uint32) (map[uint16]ifdEntry, error) {
+	if int64(offset)+2 > int64(len(t.buf)) {
+		return nil, fmt.Errorf("IFD offset out of range")
+	}
+	count := int(t.order.Uint16(t.buf[offset:]))
+	pos := int(offset) + 2
+
+	entries := make(map[uint16]ifdEntry, count)
+	for i := 0; i < count; i++ {
+		if pos+12 > len(t.buf) {
+			break
+		}
+		entry := ifdEntry{
+			tag:   t.order.Uint16(t.buf[pos:]),
+			typ:   t.order.Uint16(t.buf[pos+2:]),
+			count: t.order.Uint32(t.buf[pos+4:]),
+		}
+
+		size := int64(typeSizes[entry.typ]) * int64(entry.count)
+		if size <= 4 {
+			entry.data = t.buf[pos+8 : pos+8+int(size)]
+		} else {
+			valueOffset := int64(t.order.Uint32(t.buf[pos+8:]))
+			if valueOffset+size <= int64(len(t.buf)) {
+				entry.data = t.buf[valueOffset : valueOffset+size]
+			}
+		}
+
+		entries[entry.tag] = entry
+		pos += 12
+	}
+	return entries, nil
+}
+
+func (t *tiffReader) stringValue(e ifdEntry) string {
+	if e.typ != typeASCII {
+		return ""
+	}
+	s := string(e.data)
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+func (t *tiffReader) uintValue(e ifdEntry) uint32 {
+	switch {
+	case e.typ == typeShort && len(e.data) >= 2:
+		return uint32(t.order.Uint16(e.data))
+	case (e.typ == typeLong || e.typ == typeSLong) && len(e.data) >= 4:
+		return t.order.Uint32(e.data)
+	}
+	return 0
+}
+
+func (t *tiffReader) rational(data []byte, i int) float64 {
+	if len(data) < (i+1)*8 {
+		return 0
+	}
+	num := t.order.Uint32(data[i*8:])
+	den := t.order.Uint32(data[i*8+4:])
+	if den == 0 {
+		return 0
+	}
+	return float64(num) / float64(den)
+}
+
+// coordinate converts a degrees/minutes/seconds GPS value to decimal degrees
+func (t *tiffReader) coordinate(e ifdEntry, ref, negativeRef string) *float64 {
+	if e.typ != typeRational || e.count < 3 || len(e.data) < 24 {
+		return nil
+	}
+	value := t.rational(e.data, 0) + t.rational(e.data, 1)/60 + t.rational(e.data, 2)/3600
+	if strings.EqualFold(ref, negativeRef) {
+		value = -value
+	}
+	return &value
+}
+
+// appleContentID reads the Live Photo content identifier from an Apple maker
+// note, returning an empty string for other maker notes
+func appleContentID(makerNote []byte) string {
+	if !bytes.HasPrefix(makerNote, appleMakerNoteHeader) {
+		return ""
+	}
+	t := &tiffReader{buf: makerNote, order: binary.BigEndian}
+	entries, err := t.readIFD(14)
+	if err != nil {
+		return ""
+	}
+	return t.stringValue(entries[appleContentIdentifier])
+}
+
+// parseExifTime parses an EXIF date, applying the offset tag when present.
+// Timestamps without an offset are taken to be in the local time zone.
+func parseExifTime(value, offset string) *time.Time {
+	if value == "" || strings.HasPrefix(value, "0000") {
+		return nil
+	}
+
+	if offset != "" {
+		if t, err := time.Parse(exifTimeLayout+"-07:00", value+offset); err == nil {
+			return &t
+		}
+	}
+
+	t, err := time.ParseInLocation(exifTimeLayout, value, time.Local)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// extractJPEG walks the JPEG segments looking for the EXIF APP1 block and the
+// frame header carrying the image dimensions
+func extractJPEG(r io.Reader) (*Metadata, error) {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil {
+		return nil, err
+	}
+	if soi != [2]byte{0xFF, 0xD8} {
+		return nil, fmt.Errorf("not a JPEG file")
+	}
+
+	m := &Metadata{}
+	width, height := 0, 0
+	for {
+		var marker [2]byte
+		if _, err := io.ReadFull(r, marker[:]); err != nil {
+			break
+		}
+		if marker[0] != 0xFF {
+			break
+		}
+		// Start of scan - no more metadata segments follow
+		if marker[1] == 0xDA || marker[1] == 0xD9 {
+			break
+		}
+
+		var lengthBytes [2]byte
+		if _, err := io.ReadFull(r, lengthBytes[:]); err != nil {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(lengthBytes[:])) - 2
+		if length < 0 {
+			break
+		}
+		segment := make([]byte, length)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			break
+		}
+
+		switch {
+		case marker[1] == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")):
+			exif, err := parseTIFF(segment[6:])
+			if err == nil {
+				m = exif
+			}
+		case isSOFMarker(marker[1]) && len(segment) >= 5:
+			height = int(binary.BigEndian.Uint16(segment[1:3]))
+			width = int(binary.BigEndian.Uint16(segment[3:5]))
+		}
+	}
+
+	if m.Width == 0 || m.Height == 0 {
+		m.Width, m.Height = width, height
+	}
+	return m, nil
+}
+
+// isSOFMarker reports whether marker is a start-of-frame marker
+func isSOFMarker(marker byte) bool {
+	return marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC
+}
+
+// extractTIFF reads the leading part of a TIFF file and decodes its IFDs
+func extractTIFF(r io.ReaderAt, size int64) (*Metadata, error) {
+	if size > maxTIFFSize {
+		size = maxTIFFSize
+	}
+	buf := make([]byte, size)
+	n, err := r.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return parseTIFF(buf[:n])
+}
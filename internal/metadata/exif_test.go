@@ -0,0 +1,210 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// testOrder is a byte order that can also append
+type testOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// testField is an IFD entry written by appendIFD
+type testField struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+func asciiField(tag uint16, s string) testField {
+	return testField{tag, typeASCII, uint32(len(s) + 1), append([]byte(s), 0)}
+}
+
+func longField(order testOrder, tag uint16, v uint32) testField {
+	return testField{tag, typeLong, 1, order.AppendUint32(nil, v)}
+}
+
+// appendIFD writes an IFD at the end of buf, followed by the values that do
+// not fit in their entries. Values of up to 4 bytes are copied into the entry
+// as given, so a crafted entry can carry any value offset.
+func appendIFD(buf []byte, order testOrder, fields []testField, next uint32) []byte {
+	dataOffset := len(buf) + 2 + 12*len(fields) + 4
+	var data []byte
+	buf = order.AppendUint16(buf, uint16(len(fields)))
+	for _, f := range fields {
+		buf = order.AppendUint16(buf, f.tag)
+		buf = order.AppendUint16(buf, f.typ)
+		buf = order.AppendUint32(buf, f.count)
+		if len(f.value) <= 4 {
+			buf = append(buf, f.value...)
+			buf = append(buf, make([]byte, 4-len(f.value))...)
+			continue
+		}
+		buf = order.AppendUint32(buf, uint32(dataOffset+len(data)))
+		data = append(data, f.value...)
+	}
+	buf = order.AppendUint32(buf, next)
+	return append(buf, data...)
+}
+
+// tiffHeader returns a TIFF header pointing at an IFD0 at offset
+func tiffHeader(order testOrder, offset uint32) []byte {
+	buf := []byte("MM")
+	if order == binary.LittleEndian {
+		buf = []byte("II")
+	}
+	buf = order.AppendUint16(buf, 42)
+	return order.AppendUint32(buf, offset)
+}
+
+// testEXIF builds a minimal EXIF block with a camera, a capture time and an
+// Exif IFD stored before IFD0
+func testEXIF(order testOrder) []byte {
+	buf := tiffHeader(order, 0)
+	exifOffset := uint32(len(buf))
+	buf = appendIFD(buf, order, []testField{
+		asciiField(tagDateTimeOriginal, "2024:05:01 10:30:00"),
+		asciiField(tagOffsetTimeOriginal, "+02:00"),
+		longField(order, tagPixelXDimension, 4032),
+		longField(order, tagPixelYDimension, 3024),
+	}, 0)
+	ifd0Offset := uint32(len(buf))
+	buf = appendIFD(buf, order, []testField{
+		asciiField(tagMake, "Apple"),
+		asciiField(tagModel, "iPhone 15"),
+		longField(order, tagExifIFD, exifOffset),
+	}, 0)
+	order.PutUint32(buf[4:8], ifd0Offset)
+	return buf
+}
+
+func TestParseTIFF(t *testing.T) {
+	takenAt := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+	for _, order := range []testOrder{binary.BigEndian, binary.LittleEndian} {
+		m, err := parseTIFF(testEXIF(order))
+		if err != nil {
+			t.Fatalf("%s: parseTIFF failed: %v", order, err)
+		}
+		if m.CameraMake != "Apple" || m.CameraModel != "iPhone 15" || m.Width != 4032 || m.Height != 3024 {
+			t.Errorf("%s: parseTIFF = %+v", order, m)
+		}
+		if m.TakenAt == nil || !m.TakenAt.Equal(takenAt) {
+			t.Errorf("%s: TakenAt = %v, want %s", order, m.TakenAt, takenAt)
+		}
+	}
+}
+
+func TestParseTIFFMalformed(t *testing.T) {
+	order := binary.BigEndian
+	valid := testEXIF(order)
+	withIFD0 := func(fields ...testField) []byte {
+		return appendIFD(tiffHeader(order, 8), order, fields, 0)
+	}
+	tests := []struct {
+		name    string
+		buf     []byte
+		wantErr bool
+		want    Metadata
+	}{
+		{"empty", nil, true, Metadata{}},
+		{"shorter than the header", valid[:7], true, Metadata{}},
+		{"unknown byte order", append([]byte("XX"), valid[2:]...), true, Metadata{}},
+		{"wrong magic number", append([]byte("MM\x00\x2b"), valid[4:]...), true, Metadata{}},
+		{"IFD0 past the end", tiffHeader(order, 0xFFFFFFF0), true, Metadata{}},
+		{"IFD0 at the last byte", append(tiffHeader(order, 8), 0), true, Metadata{}},
+		{"more entries than data", append(tiffHeader(order, 8), 0xFF, 0xFF), false, Metadata{}},
+		{"value past the end", withIFD0(testField{tagMake, typeASCII, 16, []byte{0, 0, 1, 0}}), false, Metadata{}},
+		{"huge count", withIFD0(testField{tagModel, typeRational, 0xFFFFFFFF, []byte{0, 0, 0, 8}}), false, Metadata{}},
+		{"Exif IFD past the end", withIFD0(asciiField(tagMake, "Canon"), longField(order, tagExifIFD, 0xFFFFFFFF)), false, Metadata{CameraMake: "Canon"}},
+		{"Exif IFD is IFD0", withIFD0(asciiField(tagMake, "Canon"), longField(order, tagExifIFD, 8)), false, Metadata{CameraMake: "Canon"}},
+		{"GPS IFD is IFD0", withIFD0(asciiField(tagMake, "Canon"), longField(order, tagGPSIFD, 8)), false, Metadata{CameraMake: "Canon"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := parseTIFF(tt.buf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTIFF error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if m.CameraMake != tt.want.CameraMake || m.CameraModel != tt.want.CameraModel || m.TakenAt != nil || m.HasLocation() {
+				t.Errorf("parseTIFF = %+v, want %+v", m, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTIFFTruncated(t *testing.T) {
+	valid := testEXIF(binary.LittleEndian)
+	for n := range valid {
+		// Must not panic; whatever is still in range may be returned
+		parseTIFF(valid[:n])
+	}
+}
+
+func TestAppleContentID(t *testing.T) {
+	order := binary.BigEndian
+	makerNote := append([]byte(nil), appleMakerNoteHeader...)
+	makerNote = append(makerNote, "\x00\x01MM"...)
+	makerNote = appendIFD(makerNote, order, []testField{
+		asciiField(appleContentIdentifier, "8F3A1C2B-1234"),
+	}, 0)
+
+	tests := []struct {
+		name      string
+		makerNote []byte
+		want      string
+	}{
+		{"apple", makerNote, "8F3A1C2B-1234"},
+		{"other vendor", []byte("Nikon\x00\x02\x10\x00\x00"), ""},
+		{"header only", appleMakerNoteHeader, ""},
+		{"truncated value", makerNote[:len(makerNote)-4], ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := appleContentID(tt.makerNote); got != tt.want {
+				t.Errorf("appleContentID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractJPEG(t *testing.T) {
+	segment := func(marker byte, payload []byte) []byte {
+		buf := []byte{0xFF, marker}
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(payload)+2))
+		return append(buf, payload...)
+	}
+	sof := []byte{8, 0x01, 0x00, 0x02, 0x00, 3} // 512x256
+	jpeg := []byte{0xFF, 0xD8}
+	jpeg = append(jpeg, segment(0xE1, append([]byte("Exif\x00\x00"), testEXIF(binary.BigEndian)...))...)
+	jpeg = append(jpeg, segment(0xC0, sof)...)
+	jpeg = append(jpeg, 0xFF, 0xDA)
+
+	m, err := extractJPEG(bytes.NewReader(jpeg))
+	if err != nil {
+		t.Fatalf("extractJPEG failed: %v", err)
+	}
+	if m.CameraModel != "iPhone 15" || m.Width != 4032 || m.Height != 3024 {
+		t.Errorf("extractJPEG = %+v", m)
+	}
+
+	// The frame size is used when the EXIF block has none
+	bare := append([]byte{0xFF, 0xD8}, segment(0xC0, sof)...)
+	if m, err := extractJPEG(bytes.NewReader(bare)); err != nil || m.Width != 512 || m.Height != 256 {
+		t.Errorf("extractJPEG without EXIF = %+v, %v, want 512x256", m, err)
+	}
+
+	if _, err := extractJPEG(bytes.NewReader([]byte("GIF89a"))); err == nil {
+		t.Error("extractJPEG succeeded on a GIF, want an error")
+	}
+	for n := range jpeg {
+		extractJPEG(bytes.NewReader(jpeg[:n]))
+	}
+}
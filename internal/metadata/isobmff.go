@@ -0,0 +1,520 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxBoxSize caps how much of a single box is read into memory
+const maxBoxSize = 64 * 1024 * 1024
+
+// macEpoch is the reference time for QuickTime and MP4 timestamps
+var macEpoch = time.Date(1904, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// maxMovieSeconds is the latest mvhd time that fits in a time.Duration after
+// macEpoch, early in 2196. Larger values come from corrupt atoms.
+const maxMovieSeconds = uint64(math.MaxInt64 / int64(time.Second))
+
+// iso6709Pattern matches locations such as "+37.7749-122.4194+010.000/"
+var iso6709Pattern = regexp.MustCompile(`^([+-]\d+(?:\.\d+)?)([+-]\d+(?:\.\d+)?)`)
+
+type box struct {
+	typ    string
+	offset int64 // start of the box payload
+	size   int64 // size of the box payload
+}
+
+// readBoxes lists the boxes stored between start and end
+func readBoxes(r io.ReaderAt, start, end int64) ([]box, error) {
+	var boxes []box
+	for pos := start; pos+8 <= end; {
+		var header [16]byte
+		if _, err := r.ReadAt(header[:8], pos); err != nil {
+			return boxes, err
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		typ := string(header[4:8])
+		headerSize := int64(8)
+
+		switch size {
+		case 0:
+			size = end - pos
+		case 1:
+			if _, err := r.ReadAt(header[8:16], pos+8); err != nil {
+				return boxes, err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+
+		// Compared without adding to pos, which a 64-bit size can overflow
+		if size < headerSize || size > end-pos {
+			break
+		}
+
+		boxes = append(boxes, box{typ: typ, offset: pos + headerSize, size: size - headerSize})
+		pos += size
+	}
+	return boxes, nil
+}
+
+// readBoxesFrom lists the boxes stored in an in-memory buffer
+func readBoxesFrom(buf []byte) []box {
+	boxes, _ := readBoxes(bytes.NewReader(buf), 0, int64(len(buf)))
+	return boxes
+}
+
+// findBox returns the first box of the given type
+func findBox(boxes []box, typ string) (box, bool) {
+	for _, b := range boxes {
+		if b.typ == typ {
+			return b, true
+		}
+	}
+	return box{}, false
+}
+
+// readPayload reads the payload of b into memory
+func readPayload(r io.ReaderAt, b box) ([]byte, error) {
+	if b.size > maxBoxSize {
+		return nil, fmt.Errorf("%s box too large (%d bytes)", b.typ, b.size)
+	}
+	buf := make([]byte, b.size)
+	if _, err := r.ReadAt(buf, b.offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// subBuffer returns the payload of b when b was listed from buf, or nil if
+// b does not lie within buf
+func subBuffer(buf []byte, b box) []byte {
+	if b.offset < 0 || b.size < 0 || b.offset > int64(len(buf)) || b.size > int64(len(buf))-b.offset {
+		return nil
+	}
+	return buf[b.offset : b.offset+b.size]
+}
+
+// readUint reads a big-endian unsigned integer of 0, 2, 4 or 8 bytes
+func readUint(buf []byte, pos *int, size int) (uint64, error) {
+	if *pos+size > len(buf) {
+		return 0, fmt.Errorf("unexpected end of box")
+	}
+	var v uint64
+	switch size {
+	case 0:
+		v = 0
+	case 1:
+		v = uint64(buf[*pos])
+	case 2:
+		v = uint64(binary.BigEndian.Uint16(buf[*pos:]))
+	case 4:
+		v = uint64(binary.BigEndian.Uint32(buf[*pos:]))
+	case 8:
+		v = binary.BigEndian.Uint64(buf[*pos:])
+	default:
+		return 0, fmt.Errorf("unsupported field size %d", size)
+	}
+	*pos += size
+	return v, nil
+}
+
+// extractHEIF reads the EXIF item and image size properties of a HEIC file
+func extractHEIF(r io.ReaderAt, size int64) (*Metadata, error) {
+	top, err := readBoxes(r, 0, size)
+	if err != nil && len(top) == 0 {
+		return nil, err
+	}
+
+	metaBox, ok := findBox(top, "meta")
+	if !ok {
+		return nil, fmt.Errorf("no meta box found")
+	}
+	meta, err := readPayload(r, metaBox)
+	if err != nil {
+		return nil, err
+	}
+	if len(meta) < 4 {
+		return nil, fmt.Errorf("meta box too short")
+	}
+	// meta is a full box; skip version and flags
+	meta = meta[4:]
+	children := readBoxesFrom(meta)
+
+	m := &Metadata{}
+	if exif, err := readHEIFExif(r, meta, children); err == nil {
+		m = exif
+	}
+
+	if m.Width == 0 || m.Height == 0 {
+		m.Width, m.Height = largestImageSpatialExtent(meta, children)
+	}
+	return m, nil
+}
+
+// readHEIFExif locates the Exif item through iinf/iloc and decodes it
+func readHEIFExif(r io.ReaderAt, meta []byte, children []box) (*Metadata, error) {
+	iinf, ok := findBox(children, "iinf")
+	if !ok {
+		return nil, fmt.Errorf("no iinf box found")
+	}
+	exifID, err := findItemByType(subBuffer(meta, iinf), "Exif")
+	if err != nil {
+		return nil, err
+	}
+
+	iloc, ok := findBox(children, "iloc")
+	if !ok {
+		return nil, fmt.Errorf("no iloc box found")
+	}
+	offset, length, err := findItemLocation(subBuffer(meta, iloc), exifID)
+	if err != nil {
+		return nil, err
+	}
+	if length > maxBoxSize || length < 4 {
+		return nil, fmt.Errorf("invalid Exif item length %d", length)
+	}
+
+	data := make([]byte, length)
+	if _, err := r.ReadAt(data, int64(offset)); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	// The item starts with the offset of the TIFF header within the payload
+	headerOffset := int(binary.BigEndian.Uint32(data[:4]))
+	data = data[4:]
+	if headerOffset > len(data) {
+		return nil, fmt.Errorf("invalid Exif header offset")
+	}
+	data = data[headerOffset:]
+	data = bytes.TrimPrefix(data, []byte("Exif\x00\x00"))
+
+	return parseTIFF(data)
+}
+
+// findItemByType returns the ID of the first item of itemType in an iinf box
+func findItemByType(iinf []byte, itemType string) (uint32, error) {
+	if len(iinf) < 4 {
+		return 0, fmt.Errorf("iinf box too short")
+	}
+	pos := 4
+	countSize := 2
+	if iinf[0] > 0 {
+		countSize = 4
+	}
+	if _, err := readUint(iinf, &pos, countSize); err != nil {
+		return 0, err
+	}
+
+	for _, infe := range readBoxesFrom(iinf[pos:]) {
+		if infe.typ != "infe" {
+			continue
+		}
+		data := subBuffer(iinf[pos:], infe)
+		if len(data) < 4 || data[0] < 2 {
+			continue
+		}
+		p := 4
+		idSize := 2
+		if data[0] >= 3 {
+			idSize = 4
+		}
+		id, err := readUint(data, &p, idSize)
+		if err != nil {
+			continue
+		}
+		p += 2 // item_protection_index
+		if p+4 > len(data) {
+			continue
+		}
+		if string(data[p:p+4]) == itemType {
+			return uint32(id), nil
+		}
+	}
+	return 0, fmt.Errorf("no %s item found", itemType)
+}
+
+// findItemLocation returns the file offset and length of an item in an iloc box
+func findItemLocation(iloc []byte, itemID uint32) (uint64, uint64, error) {
+	if len(iloc) < 6 {
+		return 0, 0, fmt.Errorf("iloc box too short")
+	}
+	version := iloc[0]
+	offsetSize := int(iloc[4] >> 4)
+	lengthSize := int(iloc[4] & 0x0F)
+	baseOffsetSize := int(iloc[5] >> 4)
+	indexSize := 0
+	if version == 1 || version == 2 {
+		indexSize = int(iloc[5] & 0x0F)
+	}
+
+	pos := 6
+	countSize := 2
+	if version == 2 {
+		countSize = 4
+	}
+	count, err := readUint(iloc, &pos, countSize)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for i := uint64(0); i < count; i++ {
+		id, err := readUint(iloc, &pos, countSize)
+		if err != nil {
+			return 0, 0, err
+		}
+		if version == 1 || version == 2 {
+			pos += 2 // construction_method
+		}
+		pos += 2 // data_reference_index
+		baseOffset, err := readUint(iloc, &pos, baseOffsetSize)
+		if err != nil {
+			return 0, 0, err
+		}
+		extentCount, err := readUint(iloc, &pos, 2)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		var offset, length uint64
+		for e := uint64(0); e < extentCount; e++ {
+			if _, err := readUint(iloc, &pos, indexSize); err != nil {
+				return 0, 0, err
+			}
+			extentOffset, err := readUint(iloc, &pos, offsetSize)
+			if err != nil {
+				return 0, 0, err
+			}
+			extentLength, err := readUint(iloc, &pos, lengthSize)
+			if err != nil {
+				return 0, 0, err
+			}
+			if e == 0 {
+				offset = baseOffset + extentOffset
+			}
+			length += extentLength
+		}
+
+		if uint32(id) == itemID {
+			return offset, length, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("item %d not found in iloc", itemID)
+}
+
+// largestImageSpatialExtent returns the largest ispe property in iprp/ipco,
+// which for tiled HEIC images is the size of the full picture
+func largestImageSpatialExtent(meta []byte, children []box) (int, int) {
+	iprp, ok := findBox(children, "iprp")
+	if !ok {
+		return 0, 0
+	}
+	iprpData := subBuffer(meta, iprp)
+	ipco, ok := findBox(readBoxesFrom(iprpData), "ipco")
+	if !ok {
+		return 0, 0
+	}
+	ipcoData := subBuffer(iprpData, ipco)
+
+	width, height := 0, 0
+	for _, b := range readBoxesFrom(ipcoData) {
+		if b.typ != "ispe" {
+			continue
+		}
+		data := subBuffer(ipcoData, b)
+		if len(data) < 12 {
+			continue
+		}
+		w := int(binary.BigEndian.Uint32(data[4:8]))
+		h := int(binary.BigEndian.Uint32(data[8:12]))
+		if w*h > width*height {
+			width, height = w, h
+		}
+	}
+	return width, height
+}
+
+// extractMovie reads the movie header, track headers and QuickTime metadata
+// of an MP4 or MOV file
+func extractMovie(r io.ReaderAt, size int64) (*Metadata, error) {
+	top, err := readBoxes(r, 0, size)
+	if err != nil && len(top) == 0 {
+		return nil, err
+	}
+
+	moovBox, ok := findBox(top, "moov")
+	if !ok {
+		return nil, fmt.Errorf("no moov atom found")
+	}
+	moov, err := readPayload(r, moovBox)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Metadata{}
+	children := readBoxesFrom(moov)
+
+	if mvhd, ok := findBox(children, "mvhd"); ok {
+		m.TakenAt = parseMovieHeaderTime(subBuffer(moov, mvhd))
+	}
+
+	for _, trak := range children {
+		if trak.typ != "trak" {
+			continue
+		}
+		trakData := subBuffer(moov, trak)
+		tkhd, ok := findBox(readBoxesFrom(trakData), "tkhd")
+		if !ok {
+			continue
+		}
+		data := subBuffer(trakData, tkhd)
+		if len(data) < 8 {
+			continue
+		}
+		// Width and height are the trailing 16.16 fixed-point fields
+		w := int(binary.BigEndian.Uint32(data[len(data)-8:]) >> 16)
+		h := int(binary.BigEndian.Uint32(data[len(data)-4:]) >> 16)
+		if w > 0 && h > 0 {
+			m.Width, m.Height = w, h
+			break
+		}
+	}
+
+	if udta, ok := findBox(children, "udta"); ok {
+		readUserData(m, subBuffer(moov, udta))
+	}
+	if meta, ok := findBox(children, "meta"); ok {
+		readQuickTimeMetadata(m, subBuffer(moov, meta))
+	}
+
+	return m, nil
+}
+
+// parseMovieHeaderTime returns the creation time stored in an mvhd atom
+func parseMovieHeaderTime(mvhd []byte) *time.Time {
+	if len(mvhd) < 8 {
+		return nil
+	}
+	pos := 4
+	size := 4
+	if mvhd[0] == 1 {
+		size = 8
+	}
+	seconds, err := readUint(mvhd, &pos, size)
+	if err != nil || seconds == 0 || seconds > maxMovieSeconds {
+		return nil
+	}
+	t := macEpoch.Add(time.Duration(seconds) * time.Second)
+	return &t
+}
+
+// readUserData reads the legacy ©mak, ©mod and ©xyz atoms in udta
+func readUserData(m *Metadata, udta []byte) {
+	for _, b := range readBoxesFrom(udta) {
+		data := subBuffer(udta, b)
+		if len(data) < 4 {
+			continue
+		}
+		// Each value is prefixed by its length and a language code
+		n := int(binary.BigEndian.Uint16(data[:2]))
+		if 4+n > len(data) {
+			n = len(data) - 4
+		}
+		value := strings.TrimSpace(string(data[4 : 4+n]))
+
+		switch b.typ {
+		case "\xa9mak":
+			m.CameraMake = value
+		case "\xa9mod":
+			m.CameraModel = value
+		case "\xa9xyz":
+			m.Latitude, m.Longitude = parseISO6709(value)
+		}
+	}
+}
+
+// readQuickTimeMetadata reads the mdta keys written by Apple devices
+func readQuickTimeMetadata(m *Metadata, meta []byte) {
+	// QuickTime meta atoms have no version/flags, MP4 ones do
+	if len(meta) >= 8 && string(meta[4:8]) != "hdlr" {
+		meta = meta[4:]
+	}
+	children := readBoxesFrom(meta)
+
+	keysBox, ok := findBox(children, "keys")
+	if !ok {
+		return
+	}
+	ilstBox, ok := findBox(children, "ilst")
+	if !ok {
+		return
+	}
+
+	keysData := subBuffer(meta, keysBox)
+	if len(keysData) < 8 {
+		return
+	}
+	var keys []string
+	for _, k := range readBoxesFrom(keysData[8:]) {
+		keys = append(keys, string(subBuffer(keysData[8:], k)))
+	}
+
+	values := make(map[string]string)
+	ilst := subBuffer(meta, ilstBox)
+	for _, item := range readBoxesFrom(ilst) {
+		index := int(binary.BigEndian.Uint32([]byte(item.typ)))
+		if index < 1 || index > len(keys) {
+			continue
+		}
+		itemData := subBuffer(ilst, item)
+		data, ok := findBox(readBoxesFrom(itemData), "data")
+		if !ok || data.size < 8 {
+			continue
+		}
+		// Skip the type indicator and locale
+		values[keys[index-1]] = string(subBuffer(itemData, data)[8:])
+	}
+
+	if v := values["com.apple.quicktime.make"]; v != "" {
+		m.CameraMake = v
+	}
+	if v := values["com.apple.quicktime.model"]; v != "" {
+		m.CameraModel = v
+	}
+	if v := values["com.apple.quicktime.creationdate"]; v != "" {
+		if t, err := time.Parse("2006-01-02T15:04:05-0700", v); err == nil {
+			m.TakenAt = &t
+		} else if t, err := time.Parse(time.RFC3339, v); err == nil {
+			m.TakenAt = &t
+		}
+	}
+	if v := values["com.apple.quicktime.location.ISO6709"]; v != "" {
+		m.Latitude, m.Longitude = parseISO6709(v)
+	}
+	m.ContentID = values["com.apple.quicktime.content.identifier"]
+}
+
+// parseISO6709 parses the latitude and longitude of an ISO 6709 location
+func parseISO6709(value string) (*float64, *float64) {
+	match := iso6709Pattern.FindStringSubmatch(value)
+	if match == nil {
+		return nil, nil
+	}
+	lat, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return nil, nil
+	}
+	lon, err := strconv.ParseFloat(match[2], 64)
+	if err != nil {
+		return nil, nil
+	}
+	return &lat, &lon
+}
@@ -0,0 +1,191 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testBox returns a box with a 32-bit size header
+func testBox(typ string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	buf := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	buf = append(buf, typ...)
+	return append(buf, body...)
+}
+
+func u16(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+func u32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+func u64(v uint64) []byte { return binary.BigEndian.AppendUint64(nil, v) }
+
+// macSeconds returns t as seconds since macEpoch
+func macSeconds(t time.Time) uint64 {
+	return uint64(t.Unix() - macEpoch.Unix())
+}
+
+func TestParseMovieHeaderTime(t *testing.T) {
+	takenAt := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	past32Bits := time.Date(2150, 1, 1, 0, 0, 0, 0, time.UTC)
+	rest := make([]byte, 16)
+	tests := []struct {
+		name string
+		mvhd []byte
+		want time.Time
+	}{
+		{"version 0", bytes.Join([][]byte{{0, 0, 0, 0}, u32(uint32(macSeconds(takenAt))), rest}, nil), takenAt},
+		{"version 1", bytes.Join([][]byte{{1, 0, 0, 0}, u64(macSeconds(takenAt)), rest}, nil), takenAt},
+		{"version 1 past 32 bits", bytes.Join([][]byte{{1, 0, 0, 0}, u64(macSeconds(past32Bits)), rest}, nil), past32Bits},
+		{"latest time", bytes.Join([][]byte{{1, 0, 0, 0}, u64(maxMovieSeconds)}, nil), macEpoch.Add(time.Duration(maxMovieSeconds) * time.Second)},
+		{"overflows a duration", bytes.Join([][]byte{{1, 0, 0, 0}, u64(maxMovieSeconds + 1)}, nil), time.Time{}},
+		{"largest 64-bit value", bytes.Join([][]byte{{1, 0, 0, 0}, u64(math.MaxUint64)}, nil), time.Time{}},
+		{"zero", bytes.Join([][]byte{{0, 0, 0, 0}, u32(0), rest}, nil), time.Time{}},
+		{"truncated version 1", bytes.Join([][]byte{{1, 0, 0, 0}, u32(1)}, nil), time.Time{}},
+		{"too short", []byte{0, 0, 0, 0, 1}, time.Time{}},
+		{"empty", nil, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseMovieHeaderTime(tt.mvhd)
+			switch {
+			case got == nil && !tt.want.IsZero():
+				t.Errorf("parseMovieHeaderTime = nil, want %s", tt.want)
+			case got != nil && !got.Equal(tt.want):
+				t.Errorf("parseMovieHeaderTime = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadBoxes(t *testing.T) {
+	withSize := func(size uint32, typ string) []byte {
+		return append(u32(size), typ...)
+	}
+	withLargeSize := func(size uint64, typ string) []byte {
+		return bytes.Join([][]byte{u32(1), []byte(typ), u64(size)}, nil)
+	}
+	tests := []struct {
+		name string
+		buf  []byte
+		want []string
+	}{
+		{"empty", nil, nil},
+		{"siblings", append(testBox("ftyp", []byte("heic")), testBox("free")...), []string{"ftyp", "free"}},
+		{"size to the end", append(testBox("ftyp"), withSize(0, "mdat")...), []string{"ftyp", "mdat"}},
+		{"size past the end", append(testBox("ftyp"), withSize(100, "moov")...), []string{"ftyp"}},
+		{"size below the header", append(withSize(4, "moov"), testBox("free")...), nil},
+		{"64-bit size", append(withLargeSize(24, "mdat"), make([]byte, 8)...), []string{"mdat"}},
+		{"64-bit size past the end", append(testBox("ftyp"), withLargeSize(1<<40, "mdat")...), []string{"ftyp"}},
+		{"64-bit size that overflows", append(testBox("ftyp"), withLargeSize(math.MaxUint64, "mdat")...), []string{"ftyp"}},
+		{"largest positive 64-bit size", append(testBox("ftyp"), withLargeSize(math.MaxInt64, "mdat")...), []string{"ftyp"}},
+		{"truncated 64-bit size", append(testBox("ftyp"), withSize(1, "mdat")...), []string{"ftyp"}},
+		{"truncated header", append(testBox("ftyp"), 0, 0, 0), []string{"ftyp"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, b := range readBoxesFrom(tt.buf) {
+				if b.offset+b.size > int64(len(tt.buf)) {
+					t.Errorf("%s box ends at %d, past the end of %d bytes", b.typ, b.offset+b.size, len(tt.buf))
+				}
+				got = append(got, b.typ)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readBoxes = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// testMovie builds a movie whose header holds takenAt and whose track is
+// width by height
+func testMovie(takenAt time.Time, width, height uint16) []byte {
+	mvhd := testBox("mvhd", []byte{0, 0, 0, 0}, u32(uint32(macSeconds(takenAt))), make([]byte, 92))
+	tkhd := testBox("tkhd", make([]byte, 76), u32(uint32(width)<<16), u32(uint32(height)<<16))
+	moov := testBox("moov", mvhd, testBox("trak", tkhd))
+	return append(testBox("ftyp", []byte("qt  ")), moov...)
+}
+
+func TestExtractMovie(t *testing.T) {
+	takenAt := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	movie := testMovie(takenAt, 1920, 1080)
+
+	m, err := extractMovie(bytes.NewReader(movie), int64(len(movie)))
+	if err != nil {
+		t.Fatalf("extractMovie failed: %v", err)
+	}
+	if m.TakenAt == nil || !m.TakenAt.Equal(takenAt) || m.Width != 1920 || m.Height != 1080 {
+		t.Errorf("extractMovie = %+v", m)
+	}
+
+	tests := []struct {
+		name string
+		buf  []byte
+		size int64
+	}{
+		{"no moov", testBox("ftyp"), 8},
+		{"moov past the end", bytes.Join([][]byte{testBox("ftyp"), u32(1000), []byte("moov")}, nil), 16},
+		// The stated size is larger than the data actually present
+		{"oversized moov", bytes.Join([][]byte{u32(1), []byte("moov"), u64(1 << 30)}, nil), 1 << 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if m, err := extractMovie(bytes.NewReader(tt.buf), tt.size); err == nil {
+				t.Errorf("extractMovie = %+v, want an error", m)
+			}
+		})
+	}
+
+	for n := range movie {
+		extractMovie(bytes.NewReader(movie[:n]), int64(n))
+	}
+}
+
+// testHEIF builds a HEIC file with an Exif item at exifOffset and exifLength
+// bytes long, an image size property, and the Exif item itself at the end
+func testHEIF(exifOffset, exifLength uint32) []byte {
+	infe := testBox("infe", []byte{2, 0, 0, 0}, u16(1), u16(0), []byte("Exif\x00"))
+	iinf := testBox("iinf", []byte{0, 0, 0, 0}, u16(1), infe)
+	iloc := testBox("iloc", []byte{0, 0, 0, 0, 0x44, 0x00}, u16(1),
+		u16(1), u16(0), u16(1), u32(exifOffset), u32(exifLength))
+	ispe := testBox("ispe", []byte{0, 0, 0, 0}, u32(640), u32(480))
+	meta := testBox("meta", []byte{0, 0, 0, 0}, iinf, iloc, testBox("iprp", testBox("ipco", ispe)))
+	return append(testBox("ftyp", []byte("heic")), meta...)
+}
+
+func TestExtractHEIF(t *testing.T) {
+	exif := bytes.Join([][]byte{u32(0), []byte("Exif\x00\x00"), testEXIF(binary.BigEndian)}, nil)
+	header := testHEIF(0, 0)
+	valid := append(testHEIF(uint32(len(header)), uint32(len(exif))), exif...)
+
+	tests := []struct {
+		name       string
+		buf        []byte
+		wantModel  string
+		wantWidth  int
+		wantHeight int
+	}{
+		{"exif item", valid, "iPhone 15", 4032, 3024},
+		{"exif item past the end", testHEIF(1<<30, uint32(len(exif))), "", 640, 480},
+		{"exif item too large", append(testHEIF(uint32(len(header)), math.MaxUint32), exif...), "", 640, 480},
+		{"exif item too short", append(testHEIF(uint32(len(header)), 2), exif...), "", 640, 480},
+		{"exif header offset past the item", append(testHEIF(uint32(len(header)), 8), 0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0), "", 640, 480},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := extractHEIF(bytes.NewReader(tt.buf), int64(len(tt.buf)))
+			if err != nil {
+				t.Fatalf("extractHEIF failed: %v", err)
+			}
+			if m.CameraModel != tt.wantModel || m.Width != tt.wantWidth || m.Height != tt.wantHeight {
+				t.Errorf("extractHEIF = %+v, want %q %dx%d", m, tt.wantModel, tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+
+	for n := range valid {
+		extractHEIF(bytes.NewReader(valid[:n]), int64(n))
+	}
+}
@@ -0,0 +1,70 @@
+package metadata
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrUnsupportedFormat is returned when no extractor exists for a file type
+var ErrUnsupportedFormat = errors.New("metadata extraction not supported for this file type")
+
+// Metadata holds the capture information read from a photo or video
+type Metadata struct {
+	TakenAt     *time.Time
+	CameraMake  string
+	CameraModel string
+	Width       int
+	Height      int
+	Latitude    *float64
+	Longitude   *float64
+
+	// ContentID links the still and video halves of an Apple Live Photo
+	ContentID string
+}
+
+// HasLocation reports whether GPS coordinates were found
+func (m *Metadata) HasLocation() bool {
+	return m.Latitude != nil && m.Longitude != nil
+}
+
+// Extract reads capture metadata from the file at path. JPEG, TIFF, TIFF-based
+// RAW and HEIC files are read through their EXIF block; MP4 and MOV files
+// through the movie header and QuickTime metadata atoms.
+func Extract(path string) (*Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg":
+		return extractJPEG(f)
+	case ".tif", ".tiff", ".cr2", ".nef", ".nrw", ".arw", ".srf", ".sr2", ".dng", ".pef", ".srw":
+		// TIFF-based RAW formats store their EXIF in the leading IFDs
+		return extractTIFF(f, info.Size())
+	case ".heic", ".heif":
+		return extractHEIF(f, info.Size())
+	case ".mp4", ".mov", ".m4v", ".3gp", ".3g2":
+		return extractMovie(f, info.Size())
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// CaptureTime returns the time the media was taken, falling back to the
+// file's modification time when no capture time was found
+func (m *Metadata) CaptureTime(modTime time.Time) time.Time {
+	if m != nil && m.TakenAt != nil {
+		return *m.TakenAt
+	}
+	return modTime
+}
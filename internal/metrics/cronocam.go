@@ -0,0 +1,35 @@
+package metrics
+
+// Metrics exported by CronoCam
+var (
+	FilesScanned = NewCounter("cronocam_files_scanned_total",
+		"Supported media files found while scanning.")
+	FilesSkipped = NewCounter("cronocam_files_skipped_total",
+		"Files left out by filters, pair policies, retry schedules or because they were already uploaded.")
+	FilesUploaded = NewCounter("cronocam_files_uploaded_total",
+		"Files uploaded to Google Photos.")
+	FilesFailed = NewCounter("cronocam_files_failed_total",
+		"Files that failed to upload or import.")
+	UploadErrors = NewCounter("cronocam_upload_errors_total",
+		"Upload errors by category.", "category")
+	BytesUploaded = NewCounter("cronocam_bytes_uploaded_total",
+		"Bytes of media uploaded to Google Photos.")
+
+	ChunkDuration = NewHistogram("cronocam_chunk_upload_duration_seconds",
+		"Time taken to upload one chunk.",
+		[]float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120})
+	APIResponses = NewCounter("cronocam_api_responses_total",
+		"Google Photos API responses by endpoint and HTTP status code, or \"error\" when no response was received.",
+		"endpoint", "code")
+	RateLimiterWait = NewHistogram("cronocam_rate_limiter_wait_seconds",
+		"Time spent waiting for the request rate limiter.",
+		[]float64{0.001, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10})
+
+	PendingFiles = NewGauge("cronocam_pending_files",
+		"Files waiting to be uploaded by state: imported, retry_due, retry_scheduled or failed_permanently.",
+		"state")
+	LastRunTimestamp = NewGauge("cronocam_last_run_timestamp_seconds",
+		"Unix time the last upload or import finished.")
+	LastRunSuccess = NewGauge("cronocam_last_run_success",
+		"1 if the last upload or import succeeded, 0 if it failed.")
+)
@@ -0,0 +1,284 @@
+// Package metrics keeps the counters, gauges and histograms exported in the
+// Prometheus text format, either over HTTP or as a textfile-collector file.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds a set of metrics and writes them in the text format
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	write(w io.Writer)
+}
+
+// Default is the registry the metrics of this package are registered in
+var Default = &Registry{}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteTo writes every metric in the Prometheus text exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	cw := &countingWriter{w: bw}
+	for _, m := range metrics {
+		m.write(cw)
+	}
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, bw.Flush()
+}
+
+// Handler serves the registry for Prometheus to scrape
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteTo(w)
+	})
+}
+
+// WriteTextfile writes the registry to path for the node_exporter textfile
+// collector. The file is replaced atomically so the collector never reads a
+// partial file.
+func (r *Registry) WriteTextfile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := r.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// series stores one value per combination of label values
+type series struct {
+	name       string
+	help       string
+	typ        string
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]float64
+	labels map[string][]string
+}
+
+func newSeries(name, help, typ string, labelNames []string) *series {
+	return &series{
+		name:       name,
+		help:       help,
+		typ:        typ,
+		labelNames: labelNames,
+		values:     make(map[string]float64),
+		labels:     make(map[string][]string),
+	}
+}
+
+func (s *series) update(labelValues []string, fn func(float64) float64) {
+	if len(labelValues) != len(s.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", s.name, len(s.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.labels[key]; !ok {
+		s.labels[key] = append([]string(nil), labelValues...)
+	}
+	s.values[key] = fn(s.values[key])
+}
+
+func (s *series) write(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeHeader(w, s.name, s.help, s.typ)
+	if len(s.labelNames) == 0 && len(s.values) == 0 {
+		// Unlabelled metrics are always exported, starting at zero
+		fmt.Fprintf(w, "%s 0\n", s.name)
+		return
+	}
+	for _, key := range sortedKeys(s.values) {
+		fmt.Fprintf(w, "%s%s %s\n", s.name, formatLabels(s.labelNames, s.labels[key]), formatValue(s.values[key]))
+	}
+}
+
+// Counter is a value that only goes up
+type Counter struct {
+	s *series
+}
+
+// NewCounter registers a counter in the default registry
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{s: newSeries(name, help, "counter", labelNames)}
+	Default.register(c.s)
+	return c
+}
+
+// Inc adds one to the counter
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the counter
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	c.s.update(labelValues, func(old float64) float64 { return old + v })
+}
+
+// Gauge is a value that can go up and down
+type Gauge struct {
+	s *series
+}
+
+// NewGauge registers a gauge in the default registry
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	g := &Gauge{s: newSeries(name, help, "gauge", labelNames)}
+	Default.register(g.s)
+	return g
+}
+
+// Set sets the gauge to v
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.s.update(labelValues, func(float64) float64 { return v })
+}
+
+// Histogram counts observations in buckets
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64
+
+	mu     sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram registers a histogram with the given upper bucket bounds in
+// the default registry
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	h := &Histogram{
+		name:    name,
+		help:    help,
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+	Default.register(h)
+	return h
+}
+
+// Observe records one value
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeHeader(w, h.name, h.help, "histogram")
+	for i, bound := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", h.name, formatValue(bound), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", h.name, formatValue(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", h.name, h.count)
+}
+
+func writeHeader(w io.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf("%s=\"%s\"", name, labelEscaper.Replace(values[i]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// countingWriter tracks the bytes written and the first error
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
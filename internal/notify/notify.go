@@ -0,0 +1,182 @@
+// Package notify posts run summaries to webhooks
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/navaneethkn/cronocam/internal/logging"
+)
+
+// Summary describes a finished run. It is the default payload and the data
+// of payload templates.
+type Summary struct {
+	Hostname        string    `json:"hostname"`
+	Profile         string    `json:"profile,omitempty"`
+	Command         string    `json:"command"`
+	RunID           int64     `json:"run_id"`
+	Status          string    `json:"status"`
+	StartedAt       time.Time `json:"started_at"`
+	FinishedAt      time.Time `json:"finished_at"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	FilesScanned    int64     `json:"files_scanned"`
+	FilesSkipped    int64     `json:"files_skipped"`
+	FilesUploaded   int64     `json:"files_uploaded"`
+	FilesFailed     int64     `json:"files_failed"`
+	BytesSent       int64     `json:"bytes_sent"`
+	Error           string    `json:"error,omitempty"`
+	Errors          []string  `json:"errors"`
+}
+
+// Failed reports whether the run ended with an error
+func (s *Summary) Failed() bool {
+	return s.Status != "ok"
+}
+
+// Duration returns the run time rounded to seconds
+func (s *Summary) Duration() time.Duration {
+	return time.Duration(s.DurationSeconds * float64(time.Second)).Round(time.Second)
+}
+
+// Webhook is an endpoint summaries are posted to
+type Webhook struct {
+	// Name identifies the webhook in logs and errors, which must not show
+	// the URL since it often contains a secret token
+	Name        string
+	URL         string
+	ContentType string
+	Headers     map[string]string
+	template    *template.Template
+}
+
+// templateFuncs are available in payload templates. json quotes a value for
+// use inside a JSON document.
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"join": strings.Join,
+}
+
+// NewWebhook creates a webhook. An empty payload template posts the summary
+// as JSON.
+func NewWebhook(name, url, payloadTemplate, contentType string, headers map[string]string) (*Webhook, error) {
+	w := &Webhook{Name: name, URL: url, ContentType: contentType, Headers: headers}
+	if w.ContentType == "" {
+		w.ContentType = "application/json"
+	}
+	if payloadTemplate != "" {
+		tmpl, err := template.New(name).Funcs(templateFuncs).Parse(payloadTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload template for %s: %v", name, err)
+		}
+		w.template = tmpl
+	}
+	return w, nil
+}
+
+func (w *Webhook) payload(summary *Summary) ([]byte, error) {
+	if w.template == nil {
+		return json.Marshal(summary)
+	}
+	var buf bytes.Buffer
+	if err := w.template.Execute(&buf, summary); err != nil {
+		return nil, fmt.Errorf("failed to render payload: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Notifier posts summaries with retries
+type Notifier struct {
+	client     *http.Client
+	retries    int
+	retryDelay time.Duration
+	logger     *slog.Logger
+}
+
+// New creates a notifier. Failed posts are retried up to retries times,
+// waiting retryDelay before the first retry and twice as long before each
+// further one.
+func New(client *http.Client, retries int, retryDelay time.Duration) *Notifier {
+	return &Notifier{
+		client:     client,
+		retries:    retries,
+		retryDelay: retryDelay,
+		logger:     logging.Component("notify"),
+	}
+}
+
+// Send posts summary to w, retrying network errors, rate limits and server
+// errors
+func (n *Notifier) Send(ctx context.Context, w *Webhook, summary *Summary) error {
+	body, err := w.payload(summary)
+	if err != nil {
+		return err
+	}
+
+	delay := n.retryDelay
+	var lastErr error
+	for attempt := 0; attempt <= n.retries; attempt++ {
+		if attempt > 0 {
+			n.logger.Warn("Retrying webhook", "webhook", w.Name, "attempt", attempt, "wait", delay, "err", lastErr)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			delay *= 2
+		}
+
+		retry, err := n.post(ctx, w, body)
+		if err == nil {
+			n.logger.Debug("Sent webhook", "webhook", w.Name)
+			return nil
+		}
+		lastErr = err
+		if !retry {
+			break
+		}
+	}
+	return lastErr
+}
+
+// post sends one request and reports whether a failure is worth retrying
+func (n *Notifier) post(ctx context.Context, w *Webhook, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("invalid webhook %s", w.Name)
+	}
+	req.Header.Set("Content-Type", w.ContentType)
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// The error would quote the URL
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = w.Name
+		}
+		return true, err
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("webhook %s returned status %d: %s", w.Name, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
@@ -0,0 +1,173 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receiver records the requests of a test webhook and answers them with the
+// queued status codes, then 200
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   []string
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, string(body))
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func startReceiver(t *testing.T, statuses ...int) (*receiver, string) {
+	t.Helper()
+	r := &receiver{statuses: statuses}
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return r, server.URL + "/hook/secret-token"
+}
+
+func testSummary() *Summary {
+	started := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	return &Summary{
+		Hostname:        "nas",
+		Command:         "upload",
+		RunID:           7,
+		Status:          "failed",
+		StartedAt:       started,
+		FinishedAt:      started.Add(90 * time.Second),
+		DurationSeconds: 90,
+		FilesUploaded:   3,
+		FilesFailed:     2,
+		Errors:          []string{"a.jpg: quota exceeded", "b.jpg: timeout"},
+	}
+}
+
+func testNotifier() *Notifier {
+	return New(http.DefaultClient, 2, time.Millisecond)
+}
+
+func TestSendDefaultPayload(t *testing.T) {
+	r, url := startReceiver(t)
+	w, err := NewWebhook("test", url, "", "", map[string]string{"Authorization": "Bearer x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testNotifier().Send(context.Background(), w, testSummary()); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	if len(r.requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(r.requests))
+	}
+	req := r.requests[0]
+	if req.Method != "POST" || req.URL.Path != "/hook/secret-token" {
+		t.Errorf("got %s %s", req.Method, req.URL.Path)
+	}
+	if got := req.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer x" {
+		t.Errorf("Authorization = %q", got)
+	}
+
+	var got Summary
+	if err := json.Unmarshal([]byte(r.bodies[0]), &got); err != nil {
+		t.Fatalf("payload is not JSON: %v", err)
+	}
+	want := testSummary()
+	if got.RunID != want.RunID || got.Status != want.Status || got.FilesFailed != want.FilesFailed ||
+		!got.StartedAt.Equal(want.StartedAt) || len(got.Errors) != len(want.Errors) {
+		t.Errorf("payload = %+v, want %+v", got, want)
+	}
+}
+
+func TestSendTemplate(t *testing.T) {
+	r, url := startReceiver(t)
+	tmpl := `{"text": {{ printf "%s: %d failed in %s" .Hostname .FilesFailed .Duration | json }}, "errors": {{ join .Errors "; " | json }}}`
+	w, err := NewWebhook("test", url, tmpl, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testNotifier().Send(context.Background(), w, testSummary()); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	want := `{"text": "nas: 2 failed in 1m30s", "errors": "a.jpg: quota exceeded; b.jpg: timeout"}`
+	if len(r.bodies) != 1 || r.bodies[0] != want {
+		t.Errorf("payload = %q, want %q", r.bodies, want)
+	}
+}
+
+func TestNewWebhookInvalidTemplate(t *testing.T) {
+	_, err := NewWebhook("https://example.com", "https://example.com/secret", "{{ .Nope", "", nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("error %q contains the URL", err)
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		wantErr  bool
+		wantSent int
+	}{
+		{"ok", nil, false, 1},
+		{"server error then ok", []int{500, 503}, false, 3},
+		{"rate limited then ok", []int{429}, false, 2},
+		{"server errors until retries run out", []int{500, 500, 500, 500}, true, 3},
+		{"client error is not retried", []int{400}, true, 1},
+		{"not found is not retried", []int{404}, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, url := startReceiver(t, tt.statuses...)
+			w, err := NewWebhook("test", url, "", "", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = testNotifier().Send(context.Background(), w, testSummary())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Send error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && strings.Contains(err.Error(), "secret-token") {
+				t.Errorf("error %q contains the URL", err)
+			}
+			if len(r.requests) != tt.wantSent {
+				t.Errorf("got %d requests, want %d", len(r.requests), tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestSendNetworkErrorHidesURL(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	w, err := NewWebhook("test", server.URL+"/hook/secret-token", "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = New(http.DefaultClient, 0, time.Millisecond).Send(context.Background(), w, testSummary())
+	if err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("Send error = %v, want an error without the URL", err)
+	}
+}
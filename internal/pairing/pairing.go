@@ -0,0 +1,156 @@
+package pairing
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Kind identifies what links the two files of a pair
+type Kind string
+
+const (
+	// LivePhoto pairs an Apple Live Photo still with its short video
+	LivePhoto Kind = "live_photo"
+	// RawJPEG pairs a camera JPEG with the RAW file shot alongside it
+	RawJPEG Kind = "raw_jpeg"
+)
+
+var (
+	livePhotoStills = map[string]bool{".heic": true, ".heif": true, ".jpg": true, ".jpeg": true}
+	livePhotoVideos = map[string]bool{".mov": true, ".mp4": true}
+	jpegExtensions  = map[string]bool{".jpg": true, ".jpeg": true}
+)
+
+// Pair links two files that belong to one logical photo. Primary is the file
+// the pair is known by; Companion is the file that accompanies it.
+type Pair struct {
+	Kind      Kind
+	Primary   string
+	Companion string
+	ContentID string
+}
+
+// IsCompanion reports whether path is the companion half of the pair
+func (p *Pair) IsCompanion(path string) bool {
+	return p != nil && p.Companion == path
+}
+
+// Detector finds the files that pair with a scanned file. Directory listings
+// are cached so siblings are looked up without re-reading each folder.
+type Detector struct {
+	mu   sync.Mutex
+	dirs map[string][]string
+	raw  map[string]bool
+}
+
+// NewDetector creates a detector that treats the given extensions as camera
+// RAW formats
+func NewDetector(rawExtensions map[string]bool) *Detector {
+	return &Detector{
+		dirs: make(map[string][]string),
+		raw:  rawExtensions,
+	}
+}
+
+// IsRaw reports whether path has a camera RAW extension
+func (d *Detector) IsRaw(path string) bool {
+	return d.raw[strings.ToLower(filepath.Ext(path))]
+}
+
+// RawJPEG returns the RAW+JPEG pair that path belongs to, or nil. The JPEG is
+// the primary file and the RAW file its companion.
+func (d *Detector) RawJPEG(path string) *Pair {
+	ext := strings.ToLower(filepath.Ext(path))
+
+	switch {
+	case jpegExtensions[ext]:
+		if raw := d.sibling(path, d.raw); raw != "" {
+			return &Pair{Kind: RawJPEG, Primary: path, Companion: raw}
+		}
+	case d.raw[ext]:
+		if jpeg := d.sibling(path, jpegExtensions); jpeg != "" {
+			return &Pair{Kind: RawJPEG, Primary: jpeg, Companion: path}
+		}
+	}
+	return nil
+}
+
+// ContentIDReader returns the Live Photo content identifier of a file
+type ContentIDReader func(path string) string
+
+// LivePhoto returns the Live Photo pair that path belongs to, or nil. The
+// halves must share a base name; when both carry a content identifier the
+// identifiers must also match.
+func (d *Detector) LivePhoto(path string, contentID ContentIDReader) *Pair {
+	ext := strings.ToLower(filepath.Ext(path))
+
+	var pair *Pair
+	switch {
+	case livePhotoStills[ext]:
+		if video := d.sibling(path, livePhotoVideos); video != "" {
+			pair = &Pair{Kind: LivePhoto, Primary: path, Companion: video}
+		}
+	case livePhotoVideos[ext]:
+		if still := d.sibling(path, livePhotoStills); still != "" {
+			pair = &Pair{Kind: LivePhoto, Primary: still, Companion: path}
+		}
+	}
+	if pair == nil {
+		return nil
+	}
+
+	stillID := contentID(pair.Primary)
+	videoID := contentID(pair.Companion)
+	if stillID != "" && videoID != "" && stillID != videoID {
+		return nil
+	}
+	if stillID != "" {
+		pair.ContentID = stillID
+	} else {
+		pair.ContentID = videoID
+	}
+	return pair
+}
+
+// sibling returns the file next to path with the same base name and one of
+// the given extensions, compared case-insensitively
+func (d *Detector) sibling(path string, extensions map[string]bool) string {
+	dir := filepath.Dir(path)
+	name := filepath.Base(path)
+	stem := strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))
+
+	for _, entry := range d.list(dir) {
+		if entry == name {
+			continue
+		}
+		ext := filepath.Ext(entry)
+		if extensions[strings.ToLower(ext)] && strings.ToLower(strings.TrimSuffix(entry, ext)) == stem {
+			return filepath.Join(dir, entry)
+		}
+	}
+	return ""
+}
+
+// list returns the names of the regular files in dir
+func (d *Detector) list(dir string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if files, ok := d.dirs[dir]; ok {
+		return files
+	}
+
+	var files []string
+	if entries, err := os.ReadDir(dir); err == nil {
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				files = append(files, entry.Name())
+			}
+		}
+	}
+
+	d.dirs[dir] = files
+	return files
+}
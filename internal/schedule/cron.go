@@ -0,0 +1,219 @@
+// Package schedule parses the cron expressions and quiet hours of daemon jobs
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSearch bounds the search for the next matching time so expressions
+// that never match, such as "0 0 30 2 *", do not loop forever
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+// Schedule is a parsed cron expression
+type Schedule struct {
+	expr   string
+	minute bits
+	hour   bits
+	dom    bits
+	month  bits
+	dow    bits
+	// Standard cron matches a day when either the day of month or the day
+	// of week matches, unless one of them is "*"
+	domAny bool
+	dowAny bool
+}
+
+// bits is a set of field values
+type bits uint64
+
+func (b bits) has(v int) bool {
+	return b&(1<<uint(v)) != 0
+}
+
+// field describes the allowed values of a cron field
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Both 0 and 7 are Sunday
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// macros are the supported shorthands for common expressions
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a standard five-field cron expression (minute, hour, day of
+// month, month, day of week) or one of the @hourly, @daily, @weekly,
+// @monthly and @yearly shorthands. Fields accept *, values, ranges, lists
+// and steps such as */15 or 1-5, and month and weekday names.
+func Parse(expr string) (*Schedule, error) {
+	spec := strings.TrimSpace(expr)
+	if macro, ok := macros[strings.ToLower(spec)]; ok {
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields, got %d", expr, len(fields))
+	}
+
+	s := &Schedule{expr: expr}
+	var err error
+	if s.minute, err = minuteField.parse(fields[0]); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %v", expr, err)
+	}
+	if s.hour, err = hourField.parse(fields[1]); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %v", expr, err)
+	}
+	if s.dom, err = domField.parse(fields[2]); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %v", expr, err)
+	}
+	if s.month, err = monthField.parse(fields[3]); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %v", expr, err)
+	}
+	if s.dow, err = dowField.parse(fields[4]); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %v", expr, err)
+	}
+	if s.dow.has(7) {
+		s.dow |= 1
+	}
+	s.domAny = fields[2] == "*"
+	s.dowAny = fields[4] == "*"
+	return s, nil
+}
+
+// String returns the expression the schedule was parsed from
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// Next returns the first matching minute after t, in the location of t. The
+// zero time is returned when nothing matches within five years.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxSearch)
+
+	for t.Before(limit) {
+		year, month, day := t.Date()
+		switch {
+		case !s.month.has(int(month)):
+			t = startOfDay(t, year, month+1, 1)
+		case !s.dayMatches(t):
+			t = startOfDay(t, year, month, day+1)
+		case !s.hour.has(t.Hour()):
+			// time.Date would move an hour skipped by daylight saving time
+			// back to the current one
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+		case !s.minute.has(t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// startOfDay returns midnight of a day after t, or the first hour after
+// midnight when daylight saving time skips it
+func startOfDay(t time.Time, year int, month time.Month, day int) time.Time {
+	start := time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	for !start.After(t) {
+		start = start.Add(time.Hour)
+	}
+	return start
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom.has(t.Day())
+	dowMatch := s.dow.has(int(t.Weekday()))
+	if s.domAny || s.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// parse turns a comma-separated list of values, ranges and steps into a set
+func (f field) parse(spec string) (bits, error) {
+	var set bits
+	for _, part := range strings.Split(spec, ",") {
+		rangeSpec, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %s field %q", f.name, part)
+			}
+			rangeSpec, step = part[:i], n
+		}
+
+		var lo, hi int
+		switch {
+		case rangeSpec == "*":
+			lo, hi = f.min, f.max
+		case strings.Contains(rangeSpec, "-"):
+			i := strings.Index(rangeSpec, "-")
+			var err error
+			if lo, err = f.value(rangeSpec[:i]); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(rangeSpec[i+1:]); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range in %s field %q", f.name, part)
+			}
+		default:
+			var err error
+			if lo, err = f.value(rangeSpec); err != nil {
+				return 0, err
+			}
+			hi = lo
+			// "5/15" means every 15 starting at 5
+			if step > 1 {
+				hi = f.max
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// value parses a single number or name of the field
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", f.name, s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s %d out of range %d-%d", f.name, v, f.min, f.max)
+	}
+	return v, nil
+}
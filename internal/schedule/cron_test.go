@@ -0,0 +1,132 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"* * * foo *",
+		"@often",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// 2024-06-01 is a Saturday
+	utc := func(year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"every minute", "* * * * *", utc(2024, 6, 1, 10, 7), utc(2024, 6, 1, 10, 8)},
+		{"seconds are dropped", "* * * * *", utc(2024, 6, 1, 10, 7).Add(59 * time.Second), utc(2024, 6, 1, 10, 8)},
+		{"step", "*/15 * * * *", utc(2024, 6, 1, 10, 7), utc(2024, 6, 1, 10, 15)},
+		{"step from a start", "5/20 * * * *", utc(2024, 6, 1, 10, 26), utc(2024, 6, 1, 10, 45)},
+		{"step wraps to the next hour", "5/20 * * * *", utc(2024, 6, 1, 10, 45), utc(2024, 6, 1, 11, 5)},
+		{"range with step", "0 9-17/4 * * *", utc(2024, 6, 1, 10, 0), utc(2024, 6, 1, 13, 0)},
+		{"list", "0 8,20 * * *", utc(2024, 6, 1, 8, 0), utc(2024, 6, 1, 20, 0)},
+		{"weekday names", "0 0 * * mon-fri", utc(2024, 6, 1, 0, 0), utc(2024, 6, 3, 0, 0)},
+		{"month names", "0 0 1 jan,JUL *", utc(2024, 2, 1, 0, 0), utc(2024, 7, 1, 0, 0)},
+		{"sunday as 0", "0 12 * * 0", utc(2024, 6, 3, 0, 0), utc(2024, 6, 9, 12, 0)},
+		{"sunday as 7", "0 12 * * 7", utc(2024, 6, 3, 0, 0), utc(2024, 6, 9, 12, 0)},
+		{"range up to 7", "0 12 * * 6-7", utc(2024, 6, 1, 13, 0), utc(2024, 6, 2, 12, 0)},
+		{"hourly", "@hourly", utc(2024, 6, 1, 10, 0), utc(2024, 6, 1, 11, 0)},
+		{"weekly", "@weekly", utc(2024, 6, 3, 0, 0), utc(2024, 6, 9, 0, 0)},
+		{"yearly", "@yearly", utc(2024, 6, 1, 0, 0), utc(2025, 1, 1, 0, 0)},
+		{"day of month only", "0 0 13 * *", utc(2024, 6, 1, 0, 0), utc(2024, 6, 13, 0, 0)},
+		{"day of week only", "0 0 * * fri", utc(2024, 6, 1, 0, 0), utc(2024, 6, 7, 0, 0)},
+		{"day of month or week, week first", "0 0 13 * fri", utc(2024, 6, 1, 0, 0), utc(2024, 6, 7, 0, 0)},
+		{"day of month or week, month first", "0 0 13 * fri", utc(2024, 6, 8, 0, 0), utc(2024, 6, 13, 0, 0)},
+		{"day of month and any weekday", "0 0 13 * *", utc(2024, 6, 7, 0, 0), utc(2024, 6, 13, 0, 0)},
+		{"leap day", "0 0 29 2 *", utc(2024, 3, 1, 0, 0), utc(2028, 2, 29, 0, 0)},
+		{"never", "0 0 30 2 *", utc(2024, 1, 1, 0, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.expr, err)
+			}
+			if got := s.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextDaylightSaving(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+	santiago, err := time.LoadLocation("America/Santiago")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+
+	// Wanted times are given in UTC, since a repeated hour cannot be named
+	// in local time
+	utc := func(year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		// On 2024-03-10 New York skips from 02:00 EST to 03:00 EDT
+		{"hourly across the gap", "0 * * * *", time.Date(2024, 3, 10, 1, 30, 0, 0, newYork), utc(2024, 3, 10, 7, 0)},
+		{"hour after the gap", "0 3 * * *", time.Date(2024, 3, 10, 1, 30, 0, 0, newYork), utc(2024, 3, 10, 7, 0)},
+		{"skipped time", "30 2 * * *", time.Date(2024, 3, 10, 1, 0, 0, 0, newYork), utc(2024, 3, 11, 6, 30)},
+		// On 2024-11-03 New York repeats 01:00-02:00, first EDT then EST
+		{"repeated hour, first time", "30 1 * * *", time.Date(2024, 11, 3, 0, 0, 0, 0, newYork), utc(2024, 11, 3, 5, 30)},
+		{"repeated hour, second time", "30 1 * * *", utc(2024, 11, 3, 5, 30), utc(2024, 11, 3, 6, 30)},
+		{"half hours across the repeat", "*/30 * * * *", utc(2024, 11, 3, 5, 45), utc(2024, 11, 3, 6, 0)},
+		{"hour after the repeat", "0 2 * * *", time.Date(2024, 11, 3, 0, 0, 0, 0, newYork), utc(2024, 11, 3, 7, 0)},
+		// On 2022-09-11 Santiago skips from 00:00 -04 to 01:00 -03
+		{"skipped midnight", "*/30 * * * *", time.Date(2022, 9, 10, 23, 45, 0, 0, santiago), utc(2022, 9, 11, 4, 0)},
+		{"daily across skipped midnight", "@daily", time.Date(2022, 9, 10, 12, 0, 0, 0, santiago), utc(2022, 9, 12, 3, 0)},
+		{"day after skipped midnight", "0 8 11 9 *", time.Date(2022, 9, 10, 12, 0, 0, 0, santiago), utc(2022, 9, 11, 11, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.expr, err)
+			}
+			// Starting times in the repeated hour are given in UTC too
+			from := tt.from
+			if from.Location() == time.UTC {
+				from = from.In(newYork)
+			}
+			got := s.Next(from)
+			if !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", from, got, tt.want.In(from.Location()))
+			}
+			if got.Location() != from.Location() {
+				t.Errorf("Next(%s) is in %s, want %s", from, got.Location(), from.Location())
+			}
+		})
+	}
+}
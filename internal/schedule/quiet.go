@@ -0,0 +1,66 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is a daily period such as quiet hours. A window whose end is before
+// its start, like 22:00-07:00, spans midnight.
+type Window struct {
+	expr       string
+	start, end int // minutes after midnight
+}
+
+// ParseWindow parses a window written as HH:MM-HH:MM in local time
+func ParseWindow(expr string) (*Window, error) {
+	from, to, ok := strings.Cut(strings.TrimSpace(expr), "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid time window %q: expected HH:MM-HH:MM", expr)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time window %q: %v", expr, err)
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time window %q: %v", expr, err)
+	}
+	if start == end {
+		return nil, fmt.Errorf("invalid time window %q: start and end are equal", expr)
+	}
+	return &Window{expr: expr, start: start, end: end}, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// String returns the expression the window was parsed from
+func (w *Window) String() string {
+	return w.expr
+}
+
+// Contains reports whether t falls inside the window
+func (w *Window) Contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return m >= w.start && m < w.end
+	}
+	return m >= w.start || m < w.end
+}
+
+// End returns the first end of the window after t
+func (w *Window) End(t time.Time) time.Time {
+	year, month, day := t.Date()
+	end := time.Date(year, month, day, w.end/60, w.end%60, 0, 0, t.Location())
+	if !end.After(t) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
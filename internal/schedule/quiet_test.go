@@ -0,0 +1,83 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWindowErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"22:00",
+		"22:00-",
+		"25:00-07:00",
+		"22:00-07:60",
+		"ten-eleven",
+		"07:00-07:00",
+	} {
+		if _, err := ParseWindow(expr); err == nil {
+			t.Errorf("ParseWindow(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	tests := []struct {
+		window string
+		clock  string
+		want   bool
+	}{
+		{"09:00-17:00", "08:59", false},
+		{"09:00-17:00", "09:00", true},
+		{"09:00-17:00", "16:59", true},
+		{"09:00-17:00", "17:00", false},
+		{"22:00-07:00", "21:59", false},
+		{"22:00-07:00", "22:00", true},
+		{"22:00-07:00", "23:59", true},
+		{"22:00-07:00", "00:00", true},
+		{"22:00-07:00", "06:59", true},
+		{"22:00-07:00", "07:00", false},
+		{"22:00-07:00", "12:00", false},
+		{" 23:30 - 00:15 ", "00:10", true},
+		{" 23:30 - 00:15 ", "00:15", false},
+	}
+	for _, tt := range tests {
+		w, err := ParseWindow(tt.window)
+		if err != nil {
+			t.Fatalf("ParseWindow(%q) failed: %v", tt.window, err)
+		}
+		clock, err := time.Parse("15:04", tt.clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		at := time.Date(2024, 6, 1, clock.Hour(), clock.Minute(), 30, 0, time.UTC)
+		if got := w.Contains(at); got != tt.want {
+			t.Errorf("%q Contains(%s) = %v, want %v", tt.window, tt.clock, got, tt.want)
+		}
+	}
+}
+
+func TestWindowEnd(t *testing.T) {
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2024, 6, day, hour, min, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		window string
+		from   time.Time
+		want   time.Time
+	}{
+		{"09:00-17:00", at(1, 10, 0), at(1, 17, 0)},
+		{"09:00-17:00", at(1, 17, 0), at(2, 17, 0)},
+		{"22:00-07:00", at(1, 23, 0), at(2, 7, 0)},
+		{"22:00-07:00", at(2, 3, 0), at(2, 7, 0)},
+	}
+	for _, tt := range tests {
+		w, err := ParseWindow(tt.window)
+		if err != nil {
+			t.Fatalf("ParseWindow(%q) failed: %v", tt.window, err)
+		}
+		if got := w.End(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%q End(%s) = %s, want %s", tt.window, tt.from, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package takeout
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// maxSidecarNameLength is the length Google truncates sidecar file names to,
+// including the ".json" extension
+const maxSidecarNameLength = 51
+
+// supplementalSuffix is appended to media names by newer Takeout exports
+// before ".json", and is itself often truncated
+const supplementalSuffix = ".supplemental-metadata"
+
+// albumMetadataFile describes the album a Takeout folder was exported from
+const albumMetadataFile = "metadata.json"
+
+var (
+	// duplicatePattern matches the "(1)" counter Google adds to repeated names
+	duplicatePattern = regexp.MustCompile(`^(.*)(\(\d+\))$`)
+
+	// editedSuffixes are appended to the names of edited copies, which share
+	// the sidecar of the original
+	editedSuffixes = []string{"-edited", "-bearbeitet", "-modifié", "-editado", "-modificato"}
+)
+
+// Sidecar holds the metadata Google exports next to each media file
+type Sidecar struct {
+	Title       string
+	Description string
+	TakenAt     *time.Time
+	Latitude    *float64
+	Longitude   *float64
+
+	// Album is the title of the album folder the file was exported in
+	Album string
+}
+
+type sidecarJSON struct {
+	Title          string `json:"title"`
+	Description    string `json:"description"`
+	PhotoTakenTime struct {
+		Timestamp string `json:"timestamp"`
+	} `json:"photoTakenTime"`
+	GeoData     geoJSON `json:"geoData"`
+	GeoDataExif geoJSON `json:"geoDataExif"`
+}
+
+type geoJSON struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type albumJSON struct {
+	Title     string `json:"title"`
+	AlbumData struct {
+		Title string `json:"title"`
+	} `json:"albumData"`
+}
+
+// ReadSidecar parses a Takeout JSON sidecar
+func ReadSidecar(path string) (*Sidecar, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw sidecarJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid sidecar %s: %v", path, err)
+	}
+
+	sidecar := &Sidecar{
+		Title:       raw.Title,
+		Description: strings.TrimSpace(raw.Description),
+	}
+
+	if seconds, err := strconv.ParseInt(raw.PhotoTakenTime.Timestamp, 10, 64); err == nil && seconds > 0 {
+		t := time.Unix(seconds, 0)
+		sidecar.TakenAt = &t
+	}
+
+	// Google writes 0,0 when no location is known
+	for _, geo := range []geoJSON{raw.GeoData, raw.GeoDataExif} {
+		if geo.Latitude != 0 || geo.Longitude != 0 {
+			lat, lon := geo.Latitude, geo.Longitude
+			sidecar.Latitude, sidecar.Longitude = &lat, &lon
+			break
+		}
+	}
+
+	return sidecar, nil
+}
+
+// IsSidecar reports whether path is a Takeout JSON file rather than media
+func IsSidecar(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
+
+// Index locates sidecars and album folders in a Takeout export. Directory
+// listings and album titles are cached so each folder is read only once.
+type Index struct {
+	mu     sync.Mutex
+	dirs   map[string][]string
+	albums map[string]string
+}
+
+// NewIndex creates an empty index
+func NewIndex() *Index {
+	return &Index{
+		dirs:   make(map[string][]string),
+		albums: make(map[string]string),
+	}
+}
+
+// Lookup returns the Takeout metadata for mediaPath: the contents of its
+// sidecar and the album of its folder. It returns nil if neither exists.
+func (x *Index) Lookup(mediaPath string) (*Sidecar, error) {
+	album := x.Album(filepath.Dir(mediaPath))
+
+	sidecarPath, ok := x.FindSidecar(mediaPath)
+	if !ok {
+		if album == "" {
+			return nil, nil
+		}
+		return &Sidecar{Album: album}, nil
+	}
+
+	sidecar, err := ReadSidecar(sidecarPath)
+	if err != nil {
+		return nil, err
+	}
+	sidecar.Album = album
+	return sidecar, nil
+}
+
+// FindSidecar returns the path of the sidecar describing mediaPath, following
+// Google's naming conventions for duplicates, edits and truncated names
+func (x *Index) FindSidecar(mediaPath string) (string, bool) {
+	dir := filepath.Dir(mediaPath)
+	jsonFiles := x.listSidecars(dir)
+	if len(jsonFiles) == 0 {
+		return "", false
+	}
+
+	name, counter := normalizeMediaName(filepath.Base(mediaPath))
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+
+	// Exact names first: "IMG.JPG.json", "IMG.JPG(1).json",
+	// "IMG.JPG.supplemental-metadata(1).json" and "IMG.json"
+	exact := []string{
+		name + counter + ".json",
+		name + supplementalSuffix + counter + ".json",
+		stem + counter + ".json",
+	}
+	for _, candidate := range exact {
+		for _, f := range jsonFiles {
+			if f == candidate {
+				return filepath.Join(dir, f), true
+			}
+		}
+	}
+
+	// Then truncated names, picking the longest match: the full media name
+	// with a cut supplemental-metadata suffix, as in
+	// "IMG.JPG.supplemental-metad.json", whatever its length, or any prefix
+	// of the media name and suffix when the name is at the length limit
+	best := ""
+	bestLength := 0
+	for _, f := range jsonFiles {
+		key, fileCounter := sidecarKey(f)
+		if fileCounter != counter || key == "" {
+			continue
+		}
+		rest, hasName := strings.CutPrefix(key, name)
+		match := hasName && rest != "" && strings.HasPrefix(supplementalSuffix, rest)
+		if !match && utf8.RuneCountInString(f) >= maxSidecarNameLength-len(counter) {
+			match = strings.HasPrefix(name+supplementalSuffix, key)
+		}
+		if match && len(key) > bestLength {
+			best, bestLength = f, len(key)
+		}
+	}
+	if best != "" {
+		return filepath.Join(dir, best), true
+	}
+
+	return "", false
+}
+
+// Album returns the title of the album a folder was exported from, or an
+// empty string for year folders and folders without album metadata
+func (x *Index) Album(dir string) string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if title, ok := x.albums[dir]; ok {
+		return title
+	}
+
+	title := ""
+	if data, err := os.ReadFile(filepath.Join(dir, albumMetadataFile)); err == nil {
+		var raw albumJSON
+		if json.Unmarshal(data, &raw) == nil {
+			title = raw.Title
+			if title == "" {
+				title = raw.AlbumData.Title
+			}
+		}
+	}
+
+	x.albums[dir] = strings.TrimSpace(title)
+	return x.albums[dir]
+}
+
+// listSidecars returns the names of the JSON files in dir
+func (x *Index) listSidecars(dir string) []string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if files, ok := x.dirs[dir]; ok {
+		return files
+	}
+
+	var files []string
+	entries, err := os.ReadDir(dir)
+	if err == nil {
+		for _, entry := range entries {
+			if !entry.IsDir() && IsSidecar(entry.Name()) && entry.Name() != albumMetadataFile {
+				files = append(files, entry.Name())
+			}
+		}
+	}
+
+	x.dirs[dir] = files
+	return files
+}
+
+// normalizeMediaName maps a media file name to the name its sidecar was
+// derived from. "IMG(1).JPG" becomes "IMG.JPG" with counter "(1)", and
+// "IMG-edited.JPG" becomes "IMG.JPG".
+func normalizeMediaName(name string) (string, string) {
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+
+	for _, suffix := range editedSuffixes {
+		if strings.HasSuffix(strings.ToLower(stem), suffix) {
+			stem = stem[:len(stem)-len(suffix)]
+			break
+		}
+	}
+
+	counter := ""
+	if match := duplicatePattern.FindStringSubmatch(stem); match != nil {
+		stem, counter = match[1], match[2]
+	}
+
+	return stem + ext, counter
+}
+
+// sidecarKey strips ".json" and the duplicate counter from a sidecar name. The
+// remainder is a prefix of the media name, possibly followed by part of the
+// supplemental-metadata suffix.
+func sidecarKey(name string) (string, string) {
+	key := strings.TrimSuffix(name, filepath.Ext(name))
+
+	counter := ""
+	if match := duplicatePattern.FindStringSubmatch(key); match != nil {
+		key, counter = match[1], match[2]
+	}
+
+	return key, counter
+}
@@ -0,0 +1,68 @@
+package takeout
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindSidecar(t *testing.T) {
+	// Google cuts sidecar names to 51 characters including ".json"
+	longName := strings.Repeat("x", 40) + ".jpg"
+	longerName := strings.Repeat("y", 60) + ".jpg"
+	truncated := func(name string) string {
+		return (name + supplementalSuffix)[:maxSidecarNameLength-len(".json")]
+	}
+
+	tests := []struct {
+		name  string
+		media string
+		files []string
+		want  string
+	}{
+		{"exact", "IMG_1234.JPG", []string{"IMG_1234.JPG.json", "IMG_1235.JPG.json"}, "IMG_1234.JPG.json"},
+		{"supplemental", "IMG_1234.JPG", []string{"IMG_1234.JPG.supplemental-metadata.json"}, "IMG_1234.JPG.supplemental-metadata.json"},
+		{"stem", "IMG_1234.JPG", []string{"IMG_1234.json"}, "IMG_1234.json"},
+		{"exact before truncated", "IMG_1234.JPG", []string{"IMG_1234.JPG.supplemental-meta.json", "IMG_1234.JPG.json"}, "IMG_1234.JPG.json"},
+		{"truncated suffix", "IMG_1234.JPG", []string{"IMG_1234.JPG.supplemental-metad.json"}, "IMG_1234.JPG.supplemental-metad.json"},
+		{"short truncated suffix", "IMG_1234.JPG", []string{"IMG_1234.JPG.su.json"}, "IMG_1234.JPG.su.json"},
+		{"longest truncated suffix", "B.JPG", []string{"B.JPG.supp.json", "B.JPG.supplemental-met.json"}, "B.JPG.supplemental-met.json"},
+		{"long name with truncated suffix", longName, []string{truncated(longName) + ".json"}, truncated(longName) + ".json"},
+		{"truncated long name", longerName, []string{truncated(longerName) + ".json"}, truncated(longerName) + ".json"},
+		{"edited copy", "IMG_1234-edited.JPG", []string{"IMG_1234.JPG.json"}, "IMG_1234.JPG.json"},
+		{"duplicate", "IMG_1234(1).JPG", []string{"IMG_1234.JPG.json", "IMG_1234.JPG(1).json"}, "IMG_1234.JPG(1).json"},
+		{"duplicate supplemental", "B(1).JPG", []string{"B.JPG.supplemental-metadata.json", "B.JPG.supplemental-metadata(1).json"}, "B.JPG.supplemental-metadata(1).json"},
+		{"duplicate truncated suffix", "B(1).JPG", []string{"B.JPG.supp.json", "B.JPG.supp(1).json"}, "B.JPG.supp(1).json"},
+		{"duplicate stem", "B(2).JPG", []string{"B.json", "B(2).json"}, "B(2).json"},
+		{"duplicate truncated long name", strings.Replace(longerName, ".", "(1).", 1), []string{truncated(longerName) + ".json", truncated(longerName) + "(1).json"}, truncated(longerName) + "(1).json"},
+		{"original for a duplicate", "B.JPG", []string{"B.JPG.supp(1).json"}, ""},
+		{"other duplicate", "B(2).JPG", []string{"B.JPG(1).json"}, ""},
+		{"other name", "IMG_1234.JPG", []string{"IMG_1235.JPG.json"}, ""},
+		{"prefix of a short name", "IMG_12345.JPG", []string{"IMG_1234.json"}, ""},
+		{"not a suffix", "B.JPG", []string{"B.JPG.other.json"}, ""},
+		{"album metadata only", "B.JPG", []string{"metadata.json"}, ""},
+		{"no sidecars", "B.JPG", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range append(tt.files, tt.media) {
+				if err := os.WriteFile(filepath.Join(dir, f), []byte("{}"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, ok := NewIndex().FindSidecar(filepath.Join(dir, tt.media))
+			if tt.want == "" {
+				if ok {
+					t.Errorf("FindSidecar(%q) = %q, want no match", tt.media, filepath.Base(got))
+				}
+				return
+			}
+			if !ok || got != filepath.Join(dir, tt.want) {
+				t.Errorf("FindSidecar(%q) = %q, %v, want %q", tt.media, filepath.Base(got), ok, tt.want)
+			}
+		})
+	}
+}
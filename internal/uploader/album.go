@@ -0,0 +1,132 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+type album struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// CreateAlbum creates an app-owned album and returns its ID
+func (u *Uploader) CreateAlbum(ctx context.Context, title string) (string, error) {
+	url := "https://photoslibrary.googleapis.com/v1/albums"
+
+	bodyBytes, err := json.Marshal(map[string]interface{}{
+		"album": map[string]string{"title": title},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := u.rateLimiter.Wait(ctx); err != nil {
+		return "", fmt.Errorf("rate limiter wait failed: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := u.do(req, "albums")
+	if err != nil {
+		return "", err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &APIError{Op: "failed to create album", StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var result album
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %v", err)
+	}
+	if result.ID == "" {
+		return "", fmt.Errorf("no album ID in response")
+	}
+
+	return result.ID, nil
+}
+
+// CheckAccess makes the cheapest authenticated API call there is, listing
+// one album created by cronocam. It returns an *APIError when the call is
+// rejected; a 403 means the token was accepted but cannot read albums.
+func (u *Uploader) CheckAccess(ctx context.Context) error {
+	url := "https://photoslibrary.googleapis.com/v1/albums?pageSize=1&excludeNonAppCreatedData=true"
+
+	if err := u.rateLimiter.Wait(ctx); err != nil {
+		return fmt.Errorf("rate limiter wait failed: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := u.do(req, "albums")
+	if err != nil {
+		return err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read response: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return &APIError{Op: "failed to list albums", StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return nil
+}
+
+// AddToAlbum adds media items uploaded by cronocam to an album it created
+func (u *Uploader) AddToAlbum(ctx context.Context, albumID string, mediaItemIDs []string) error {
+	url := "https://photoslibrary.googleapis.com/v1/albums/" + albumID + ":batchAddMediaItems"
+
+	bodyBytes, err := json.Marshal(map[string]interface{}{
+		"mediaItemIds": mediaItemIDs,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := u.rateLimiter.Wait(ctx); err != nil {
+		return fmt.Errorf("rate limiter wait failed: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := u.do(req, "albums")
+	if err != nil {
+		return err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read response: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return &APIError{Op: "failed to add to album", StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return nil
+}
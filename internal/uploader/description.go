@@ -0,0 +1,74 @@
+package uploader
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxDescriptionLength is the longest description the Photos API accepts,
+// which requires fewer than 1000 characters
+const MaxDescriptionLength = 999
+
+// DescriptionData is the data available to description templates
+type DescriptionData struct {
+	Path         string
+	RelativePath string
+	Folder       string
+	Filename     string
+	TakenAt      time.Time
+	CameraMake   string
+	CameraModel  string
+	Hostname     string
+}
+
+// DescriptionTemplate renders media item descriptions
+type DescriptionTemplate struct {
+	tmpl *template.Template
+}
+
+// NewDescriptionTemplate parses a text/template for media item descriptions.
+// An empty template produces empty descriptions.
+func NewDescriptionTemplate(text string) (*DescriptionTemplate, error) {
+	if strings.TrimSpace(text) == "" {
+		return &DescriptionTemplate{}, nil
+	}
+
+	tmpl, err := template.New("description").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid description template: %v", err)
+	}
+
+	// Catch references to unknown fields before any file is uploaded
+	if err := tmpl.Execute(io.Discard, DescriptionData{}); err != nil {
+		return nil, fmt.Errorf("invalid description template: %v", err)
+	}
+
+	return &DescriptionTemplate{tmpl: tmpl}, nil
+}
+
+// Render executes the template and trims the result to the API length limit
+func (d *DescriptionTemplate) Render(data DescriptionData) (string, error) {
+	if d == nil || d.tmpl == nil {
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+	if err := d.tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render description: %v", err)
+	}
+	return truncateDescription(strings.TrimSpace(buf.String())), nil
+}
+
+// truncateDescription shortens s to MaxDescriptionLength characters
+func truncateDescription(s string) string {
+	if utf8.RuneCountInString(s) <= MaxDescriptionLength {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:MaxDescriptionLength]))
+}
@@ -0,0 +1,91 @@
+package uploader
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestTruncateDescription(t *testing.T) {
+	a := func(n int) string { return strings.Repeat("a", n) }
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"empty", "", ""},
+		{"short", "Holiday", "Holiday"},
+		{"at the limit", a(999), a(999)},
+		{"over the limit", a(1000), a(999)},
+		{"two-byte runes at the limit", strings.Repeat("é", 999), strings.Repeat("é", 999)},
+		{"two-byte runes over the limit", strings.Repeat("é", 1000), strings.Repeat("é", 999)},
+		{"four-byte runes over the limit", strings.Repeat("📷", 1200), strings.Repeat("📷", 999)},
+		{"multibyte rune across the limit", a(998) + "日本", a(998) + "日"},
+		{"multibyte rune after the limit", a(999) + "日本", a(999)},
+		{"space at the cut", a(998) + " b", a(998)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateDescription(tt.in)
+			if got != tt.want {
+				t.Errorf("truncateDescription = %d runes %q..., want %d runes", utf8.RuneCountInString(got), got[max(0, len(got)-8):], utf8.RuneCountInString(tt.want))
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("truncateDescription returned invalid UTF-8")
+			}
+		})
+	}
+}
+
+func TestDescriptionTemplate(t *testing.T) {
+	data := DescriptionData{
+		RelativePath: "2024/beach.jpg",
+		Folder:       "2024",
+		Filename:     "beach.jpg",
+		TakenAt:      time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC),
+		CameraModel:  "iPhone 15",
+		Hostname:     "nas",
+	}
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"empty", "", ""},
+		{"blank", " \n\t", ""},
+		{"fields", `{{ .Folder }}/{{ .Filename }} on {{ .TakenAt.Format "2006-01-02" }} ({{ .CameraModel }})`, "2024/beach.jpg on 2024-05-01 (iPhone 15)"},
+		{"trimmed", "\n  {{ .Hostname }}  \n", "nas"},
+		{"empty output", `{{ .CameraMake }}`, ""},
+		{"truncated", `{{ .Folder }} ` + strings.Repeat("é", 1000), "2024 " + strings.Repeat("é", 994)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewDescriptionTemplate(tt.text)
+			if err != nil {
+				t.Fatalf("NewDescriptionTemplate failed: %v", err)
+			}
+			got, err := d.Render(data)
+			if err != nil || got != tt.want {
+				t.Errorf("Render = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	var none *DescriptionTemplate
+	if got, err := none.Render(data); got != "" || err != nil {
+		t.Errorf("nil template Render = %q, %v, want an empty description", got, err)
+	}
+}
+
+func TestNewDescriptionTemplateErrors(t *testing.T) {
+	for _, text := range []string{
+		"{{ .Filename",
+		"{{ .NoSuchField }}",
+		"{{ nosuchfunc }}",
+	} {
+		if _, err := NewDescriptionTemplate(text); err == nil {
+			t.Errorf("NewDescriptionTemplate(%q) succeeded, want an error", text)
+		}
+	}
+}
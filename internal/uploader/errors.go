@@ -0,0 +1,132 @@
+package uploader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrorCategory groups upload failures by how they should be handled
+type ErrorCategory string
+
+const (
+	CategoryNetwork      ErrorCategory = "network"       // connection problems and server errors
+	CategoryRateLimit    ErrorCategory = "rate_limit"    // the API quota was exceeded
+	CategoryAuth         ErrorCategory = "auth"          // the token was rejected or could not be refreshed
+	CategoryInvalidMedia ErrorCategory = "invalid_media" // the API refused the file itself
+	CategoryLocalIO      ErrorCategory = "local_io"      // the file could not be read
+	CategoryUnknown      ErrorCategory = "unknown"
+)
+
+// Categories lists every error category
+var Categories = []ErrorCategory{
+	CategoryNetwork,
+	CategoryRateLimit,
+	CategoryAuth,
+	CategoryInvalidMedia,
+	CategoryLocalIO,
+	CategoryUnknown,
+}
+
+// Transient reports whether failures of this category are likely to go away
+// on their own and should be retried automatically. Other failures need the
+// user to fix something first.
+func (c ErrorCategory) Transient() bool {
+	switch c {
+	case CategoryNetwork, CategoryRateLimit, CategoryUnknown:
+		return true
+	}
+	return false
+}
+
+// APIError is returned when the Google Photos API answers with an
+// unexpected HTTP status
+type APIError struct {
+	Op         string
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s, status: %d, body: %s", e.Op, e.StatusCode, e.Body)
+}
+
+// ReasonScopeInsufficient is the error reason of a call the scopes of the
+// token do not allow
+const ReasonScopeInsufficient = "ACCESS_TOKEN_SCOPE_INSUFFICIENT"
+
+// Reason returns the machine-readable reason Google gives in the error
+// details, such as ReasonScopeInsufficient or SERVICE_DISABLED, or an empty
+// string
+func (e *APIError) Reason() string {
+	var body struct {
+		Error struct {
+			Details []struct {
+				Reason string `json:"reason"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(e.Body), &body); err != nil {
+		return ""
+	}
+	for _, detail := range body.Error.Details {
+		if detail.Reason != "" {
+			return detail.Reason
+		}
+	}
+	return ""
+}
+
+// ItemError is returned when batchCreate accepts the request but fails to
+// create the media item from the uploaded bytes
+type ItemError struct {
+	Message string
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("failed to create media item: %s", e.Message)
+}
+
+// Classify returns the category of an error returned by the uploader
+func Classify(err error) ErrorCategory {
+	var retrieveErr *oauth2.RetrieveError
+	var apiErr *APIError
+	var itemErr *ItemError
+	var netErr net.Error
+	var urlErr *url.Error
+	var pathErr *fs.PathError
+
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &retrieveErr):
+		return CategoryAuth
+	case errors.As(err, &apiErr):
+		switch {
+		case apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden:
+			return CategoryAuth
+		case apiErr.StatusCode == http.StatusTooManyRequests:
+			return CategoryRateLimit
+		case apiErr.StatusCode == http.StatusRequestTimeout || apiErr.StatusCode >= 500:
+			return CategoryNetwork
+		default:
+			return CategoryInvalidMedia
+		}
+	case errors.As(err, &itemErr):
+		return CategoryInvalidMedia
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled),
+		errors.Is(err, io.ErrUnexpectedEOF), errors.As(err, &netErr), errors.As(err, &urlErr):
+		return CategoryNetwork
+	case errors.As(err, &pathErr):
+		return CategoryLocalIO
+	}
+	return CategoryUnknown
+}
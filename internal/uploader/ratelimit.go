@@ -3,6 +3,8 @@ package uploader
 import (
 	"context"
 	"time"
+
+	"github.com/navaneethkn/cronocam/internal/metrics"
 )
 
 type RateLimiter struct {
@@ -40,6 +42,9 @@ func (r *RateLimiter) generateTokens() {
 }
 
 func (r *RateLimiter) Wait(ctx context.Context) error {
+	start := time.Now()
+	defer func() { metrics.RateLimiterWait.Observe(time.Since(start).Seconds()) }()
+
 	select {
 	case <-r.tokens:
 		return nil
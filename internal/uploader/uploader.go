@@ -8,14 +8,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	gconfig "github.com/navaneethkn/cronocam/internal/config"
+	"github.com/navaneethkn/cronocam/internal/logging"
+	"github.com/navaneethkn/cronocam/internal/metrics"
 )
 
 type Config struct {
@@ -25,11 +29,40 @@ type Config struct {
 	MaxBurst          int
 }
 
+// rawContentTypes maps camera RAW extensions, which the mime package does not
+// know, to their vendor MIME types
+var rawContentTypes = map[string]string{
+	".cr2": "image/x-canon-cr2",
+	".cr3": "image/x-canon-cr3",
+	".nef": "image/x-nikon-nef",
+	".nrw": "image/x-nikon-nrw",
+	".arw": "image/x-sony-arw",
+	".srf": "image/x-sony-srf",
+	".sr2": "image/x-sony-sr2",
+	".dng": "image/x-adobe-dng",
+	".orf": "image/x-olympus-orf",
+	".rw2": "image/x-panasonic-rw2",
+	".raf": "image/x-fuji-raf",
+	".pef": "image/x-pentax-pef",
+	".srw": "image/x-samsung-srw",
+}
+
+// UploadOptions controls how an uploaded file appears in Google Photos
+type UploadOptions struct {
+	// Description is shown in the info panel; empty omits it
+	Description string
+	// AlbumID adds the media item to an album created by this app
+	AlbumID string
+	// Progress, if set, is called after each chunk with the bytes sent so far
+	Progress func(sent, total int64)
+}
+
 type Uploader struct {
 	client      *http.Client
 	config      Config
 	supported   map[string]bool
 	rateLimiter *RateLimiter
+	logger      *slog.Logger
 }
 
 func New(client *http.Client, config Config) (*Uploader, error) {
@@ -41,6 +74,7 @@ func New(client *http.Client, config Config) (*Uploader, error) {
 		config:      config,
 		supported:   supported,
 		rateLimiter: NewRateLimiter(config.RequestsPerSecond, config.MaxBurst),
+		logger:      logging.Component("uploader"),
 	}, nil
 }
 
@@ -64,35 +98,36 @@ func (u *Uploader) CalculateFileHash(path string) (string, error) {
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
-func (u *Uploader) UploadFile(ctx context.Context, filePath string) (string, error) {
+// UploadFile uploads a file and creates a media item for it
+func (u *Uploader) UploadFile(ctx context.Context, filePath string, opts UploadOptions) (string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		return "", fmt.Errorf("unable to open file: %v", err)
+		return "", fmt.Errorf("unable to open file: %w", err)
 	}
 	defer file.Close()
 
 	// Get file info for size
 	fileInfo, err := file.Stat()
 	if err != nil {
-		return "", fmt.Errorf("unable to get file info: %v", err)
+		return "", fmt.Errorf("unable to get file info: %w", err)
 	}
 
 	// Start resumable upload session
 	uploadURL, err := u.startResumableUpload(ctx, filePath, fileInfo.Size())
 	if err != nil {
-		return "", fmt.Errorf("unable to start upload: %v", err)
+		return "", fmt.Errorf("unable to start upload: %w", err)
 	}
 
 	// Upload file in chunks
-	uploadToken, err := u.uploadChunks(ctx, file, uploadURL, fileInfo.Size())
+	uploadToken, err := u.uploadChunks(ctx, file, uploadURL, fileInfo.Size(), opts.Progress)
 	if err != nil {
-		return "", fmt.Errorf("chunk upload failed: %v", err)
+		return "", fmt.Errorf("chunk upload failed: %w", err)
 	}
 
 	// Create media item
-	item, err := u.createMediaItem(ctx, uploadToken, filepath.Base(filePath))
+	item, err := u.createMediaItem(ctx, uploadToken, opts)
 	if err != nil {
-		return "", fmt.Errorf("failed to create media item: %v", err)
+		return "", fmt.Errorf("failed to create media item: %w", err)
 	}
 
 	return item.ID, nil
@@ -107,6 +142,9 @@ func (u *Uploader) startResumableUpload(ctx context.Context, filePath string, si
 	}
 
 	contentType := mime.TypeByExtension(filepath.Ext(filePath))
+	if raw, ok := rawContentTypes[strings.ToLower(filepath.Ext(filePath))]; ok {
+		contentType = raw
+	}
 	if contentType == "" {
 		contentType = "image/jpeg" // fallback for unknown extensions
 	}
@@ -117,7 +155,7 @@ func (u *Uploader) startResumableUpload(ctx context.Context, filePath string, si
 	req.Header.Set("X-Goog-Upload-Raw-Size", fmt.Sprintf("%d", size))
 	req.Header.Set("Content-Length", "0")
 
-	resp, err := u.client.Do(req)
+	resp, err := u.do(req, "uploads")
 	if err != nil {
 		return "", err
 	}
@@ -125,11 +163,11 @@ func (u *Uploader) startResumableUpload(ctx context.Context, filePath string, si
 	body, err := io.ReadAll(resp.Body)
 	resp.Body.Close()
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %v", err)
+		return "", fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to start upload, status: %d, body: %s", resp.StatusCode, string(body))
+		return "", &APIError{Op: "failed to start upload", StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	uploadURL := resp.Header.Get("X-Goog-Upload-URL")
@@ -140,7 +178,7 @@ func (u *Uploader) startResumableUpload(ctx context.Context, filePath string, si
 	return uploadURL, nil
 }
 
-func (u *Uploader) uploadChunks(ctx context.Context, file *os.File, uploadURL string, totalSize int64) (string, error) {
+func (u *Uploader) uploadChunks(ctx context.Context, file *os.File, uploadURL string, totalSize int64, progress func(sent, total int64)) (string, error) {
 	buffer := make([]byte, u.config.ChunkSize)
 	offset := int64(0)
 	var uploadToken string
@@ -170,7 +208,8 @@ func (u *Uploader) uploadChunks(ctx context.Context, file *os.File, uploadURL st
 		req.Header.Set("X-Goog-Upload-Offset", fmt.Sprintf("%d", offset))
 		req.Header.Set("Content-Length", fmt.Sprintf("%d", n))
 
-		resp, err := u.client.Do(req)
+		start := time.Now()
+		resp, err := u.do(req, "uploads")
 		if err != nil {
 			return "", err
 		}
@@ -178,11 +217,17 @@ func (u *Uploader) uploadChunks(ctx context.Context, file *os.File, uploadURL st
 		body, err := io.ReadAll(resp.Body)
 		resp.Body.Close()
 		if err != nil {
-			return "", fmt.Errorf("failed to read response: %v", err)
+			return "", fmt.Errorf("failed to read response: %w", err)
 		}
+		metrics.ChunkDuration.Observe(time.Since(start).Seconds())
+		u.logger.Debug("Uploaded chunk", "path", file.Name(), "offset", offset, "size", n,
+			"status", resp.StatusCode, "duration", time.Since(start))
 
 		if resp.StatusCode != http.StatusOK {
-			return "", fmt.Errorf("chunk upload failed, status: %d, body: %s", resp.StatusCode, string(body))
+			return "", &APIError{Op: "chunk upload failed", StatusCode: resp.StatusCode, Body: string(body)}
+		}
+		if progress != nil {
+			progress(offset+int64(n), totalSize)
 		}
 
 		if isLast {
@@ -201,6 +246,17 @@ func (u *Uploader) uploadChunks(ctx context.Context, file *os.File, uploadURL st
 	return uploadToken, nil
 }
 
+// do sends an API request and counts its response status under endpoint
+func (u *Uploader) do(req *http.Request, endpoint string) (*http.Response, error) {
+	resp, err := u.client.Do(req)
+	if err != nil {
+		metrics.APIResponses.Inc(endpoint, "error")
+		return nil, err
+	}
+	metrics.APIResponses.Inc(endpoint, strconv.Itoa(resp.StatusCode))
+	return resp, nil
+}
+
 type mediaItem struct {
 	ID string `json:"id"`
 }
@@ -216,19 +272,24 @@ type batchCreateResponse struct {
 	NewMediaItemResults []mediaItemResult `json:"newMediaItemResults"`
 }
 
-func (u *Uploader) createMediaItem(ctx context.Context, uploadToken, filename string) (*mediaItem, error) {
+func (u *Uploader) createMediaItem(ctx context.Context, uploadToken string, opts UploadOptions) (*mediaItem, error) {
 	url := "https://photoslibrary.googleapis.com/v1/mediaItems:batchCreate"
 
-	reqBody := map[string]interface{}{
-		"newMediaItems": []map[string]interface{}{
-			{
-				"simpleMediaItem": map[string]string{
-					"uploadToken": uploadToken,
-				},
-				"description": filename,
-			},
+	newItem := map[string]interface{}{
+		"simpleMediaItem": map[string]string{
+			"uploadToken": uploadToken,
 		},
 	}
+	if opts.Description != "" {
+		newItem["description"] = truncateDescription(opts.Description)
+	}
+
+	reqBody := map[string]interface{}{
+		"newMediaItems": []map[string]interface{}{newItem},
+	}
+	if opts.AlbumID != "" {
+		reqBody["albumId"] = opts.AlbumID
+	}
 
 	bodyBytes, err := json.Marshal(reqBody)
 	if err != nil {
@@ -240,12 +301,13 @@ func (u *Uploader) createMediaItem(ctx context.Context, uploadToken, filename st
 		if attempt > 0 {
 			// Wait before retry with exponential backoff
 			waitTime := time.Duration(attempt) * time.Second * 2
+			u.logger.Warn("Retrying media item creation", "attempt", attempt, "wait", waitTime, "err", lastErr)
 			time.Sleep(waitTime)
 		}
 
 		// Wait for rate limiter
 		if err := u.rateLimiter.Wait(ctx); err != nil {
-			return nil, fmt.Errorf("rate limiter wait failed: %v", err)
+			return nil, fmt.Errorf("rate limiter wait failed: %w", err)
 		}
 
 		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(bodyBytes))
@@ -254,7 +316,7 @@ func (u *Uploader) createMediaItem(ctx context.Context, uploadToken, filename st
 		}
 
 		req.Header.Set("Content-Type", "application/json")
-		resp, err := u.client.Do(req)
+		resp, err := u.do(req, "mediaItems:batchCreate")
 		if err != nil {
 			lastErr = err
 			continue
@@ -263,17 +325,17 @@ func (u *Uploader) createMediaItem(ctx context.Context, uploadToken, filename st
 		body, err := io.ReadAll(resp.Body)
 		resp.Body.Close()
 		if err != nil {
-			lastErr = fmt.Errorf("failed to read response: %v", err)
+			lastErr = fmt.Errorf("failed to read response: %w", err)
 			continue
 		}
 
 		if resp.StatusCode == http.StatusTooManyRequests {
-			lastErr = fmt.Errorf("rate limit exceeded (429), body: %s", string(body))
+			lastErr = &APIError{Op: "rate limit exceeded", StatusCode: resp.StatusCode, Body: string(body)}
 			continue
 		}
 
 		if resp.StatusCode != http.StatusOK {
-			lastErr = fmt.Errorf("failed to create media item, status: %d, body: %s", resp.StatusCode, string(body))
+			lastErr = &APIError{Op: "failed to create media item", StatusCode: resp.StatusCode, Body: string(body)}
 			if resp.StatusCode < 500 { // Don't retry 4xx errors except 429
 				return nil, lastErr
 			}
@@ -292,12 +354,12 @@ func (u *Uploader) createMediaItem(ctx context.Context, uploadToken, filename st
 		}
 
 		if result.NewMediaItemResults[0].Status.Message != "Success" {
-			lastErr = fmt.Errorf("failed to create media item: %s", result.NewMediaItemResults[0].Status.Message)
+			lastErr = &ItemError{Message: result.NewMediaItemResults[0].Status.Message}
 			continue
 		}
 
 		return &result.NewMediaItemResults[0].MediaItem, nil
 	}
 
-	return nil, fmt.Errorf("all retries failed, last error: %v", lastErr)
+	return nil, fmt.Errorf("all retries failed, last error: %w", lastErr)
 }